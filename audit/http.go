@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// QueryResult is the answer to an audit query. Valid reports whether the hash chain of the trail is intact.
+type QueryResult struct {
+	Entries    []Entry `json:"entries"`
+	Valid      bool    `json:"valid"`
+	ChainError string  `json:"chain_error,omitempty"`
+}
+
+// Result runs the query and verifies the chain. An error reading the trail is reported like a broken chain.
+func (t *Trail) Result(f Filter) QueryResult {
+	entries, err := t.Query(f)
+	if err == nil {
+		err = t.Verify()
+	}
+
+	result := QueryResult{Entries: entries, Valid: true}
+	if result.Entries == nil {
+		result.Entries = []Entry{}
+	}
+	if err != nil {
+		result.Valid = false
+		result.ChainError = err.Error()
+	}
+	return result
+}
+
+// Handler serves GET /audit with the filter fields as query parameters, since and until in RFC 3339.
+func Handler(t *Trail) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := Filter{
+			TenantId:        q.Get("tenant_id"),
+			RequestId:       q.Get("request_id"),
+			ConfigurationId: q.Get("configuration_id"),
+			Step:            q.Get("step"),
+		}
+
+		var err error
+		if v := q.Get("since"); v != "" && err == nil {
+			f.Since, err = time.Parse(time.RFC3339, v)
+		}
+		if v := q.Get("until"); v != "" && err == nil {
+			f.Until, err = time.Parse(time.RFC3339, v)
+		}
+		if v := q.Get("limit"); v != "" && err == nil {
+			f.Limit, err = strconv.Atoi(v)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(t.Result(f))
+	})
+}
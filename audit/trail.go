@@ -0,0 +1,338 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Steps of an issuance recorded in the trail.
+const (
+	StepRequestReceived     = "request.received"
+	StepOfferObtained       = "offer.obtained"
+	StepCredentialPrepared  = "credential.prepared"
+	StepRequestFailed       = "request.failed"
+	StepRequestReplayed     = "request.replayed"
+	StepIssueReceived       = "issue.received"
+	StepCredentialSigned    = "credential.signed"
+	StepCredentialDelivered = "credential.delivered"
+	StepIssueFailed         = "issue.failed"
+	StepNotification        = "credential.notification"
+	StepCredentialRevoked   = "credential.revoked"
+	StepOfferExpired        = "offer.expired"
+	StepCredentialApproved  = "credential.approved"
+	StepCredentialRejected  = "credential.rejected"
+	StepOfferCancelled      = "offer.cancelled"
+	StepOfferRegenerated    = "offer.regenerated"
+	StepOfferDelivered      = "offer.delivered"
+	StepOfferDeliveryFailed = "offer.delivery_failed"
+)
+
+var ErrChainBroken = errors.New("audit trail hash chain is broken")
+
+// Entry is one step of an issuance. Hash covers all other fields including the hash of the previous
+// entry, so that changing or removing an entry breaks the chain of every later one.
+type Entry struct {
+	Seq             uint64    `json:"seq"`
+	Time            time.Time `json:"time"`
+	Step            string    `json:"step"`
+	TenantId        string    `json:"tenant_id"`
+	RequestId       string    `json:"request_id,omitempty"`
+	ConfigurationId string    `json:"configuration_id,omitempty"`
+	NotificationId  string    `json:"notification_id,omitempty"`
+	Source          string    `json:"source,omitempty"`
+	CredentialHash  string    `json:"credential_hash,omitempty"`
+	Detail          string    `json:"detail,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	PrevHash        string    `json:"prev_hash"`
+	Hash            string    `json:"hash"`
+}
+
+func (e Entry) digest() string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// memoryWindow is the number of entries a trail without file keeps.
+const memoryWindow = 10000
+
+// Trail is the append-only audit log. With a file the entries are appended to it as JSON lines and
+// queries read them from there, otherwise the latest memoryWindow entries are kept in memory. A nil
+// Trail records nothing.
+type Trail struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	last   Entry
+	recent []Entry
+	// the chain of the file is verified up to this offset, ending with the entry verified
+	verifiedOffset int64
+	verified       Entry
+}
+
+// Open loads the trail stored in path and verifies its chain, new entries are appended to the file.
+// An empty path keeps the trail in memory only.
+func Open(path string) (*Trail, error) {
+	t := &Trail{path: path}
+	if path == "" {
+		return t, nil
+	}
+
+	if err := t.Verify(); err != nil {
+		return nil, fmt.Errorf("audit trail %s: %w", path, err)
+	}
+	t.size = t.verifiedOffset
+	t.last = t.verified
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	t.file = f
+
+	return t, nil
+}
+
+// Record appends the entry, setting its sequence number, time and hashes.
+func (t *Trail) Record(e Entry) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e.Seq = t.last.Seq + 1
+	e.Time = time.Now().UTC()
+	e.PrevHash = t.last.Hash
+	e.Hash = e.digest()
+
+	if t.file != nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		n, err := t.file.Write(append(b, '\n'))
+		t.size += int64(n)
+		if err != nil {
+			return err
+		}
+		if err := t.file.Sync(); err != nil {
+			return err
+		}
+	} else {
+		t.recent = append(t.recent, e)
+		if len(t.recent) > memoryWindow {
+			t.recent = slices.Delete(t.recent, 0, len(t.recent)-memoryWindow)
+		}
+	}
+
+	t.last = e
+	return nil
+}
+
+// Verify checks the hash chain. The file is verified incrementally, only the entries appended since
+// the last verification are read, the earlier ones were verified when the trail was opened.
+func (t *Trail) Verify() error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.path == "" {
+		if len(t.recent) == 0 {
+			return nil
+		}
+		first := t.recent[0]
+		return verify(t.recent, first.Seq-1, first.PrevHash)
+	}
+
+	f, err := os.Open(t.path)
+	if errors.Is(err, fs.ErrNotExist) && t.verifiedOffset == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.verifiedOffset {
+		return fmt.Errorf("%w, the trail was truncated after entry %d", ErrChainBroken, t.verified.Seq)
+	}
+	if _, err := f.Seek(t.verifiedOffset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("%w at entry %d: %w", ErrChainBroken, t.verified.Seq+1, err)
+		}
+		if err := verify([]Entry{e}, t.verified.Seq, t.verified.Hash); err != nil {
+			return err
+		}
+		t.verified = e
+		t.verifiedOffset += int64(len(line))
+	}
+}
+
+// verify checks that entries continue the chain after the entry seq with the hash prev.
+func verify(entries []Entry, seq uint64, prev string) error {
+	for i, e := range entries {
+		if e.Seq != seq+uint64(i)+1 || e.PrevHash != prev || e.Hash != e.digest() {
+			return fmt.Errorf("%w at entry %d", ErrChainBroken, seq+uint64(i)+1)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Filter selects entries of a query, empty fields match every entry.
+type Filter struct {
+	TenantId        string    `json:"tenant_id,omitempty"`
+	RequestId       string    `json:"request_id,omitempty"`
+	ConfigurationId string    `json:"configuration_id,omitempty"`
+	Step            string    `json:"step,omitempty"`
+	Since           time.Time `json:"since,omitempty"`
+	Until           time.Time `json:"until,omitempty"`
+	Limit           int       `json:"limit,omitempty"`
+}
+
+func (f Filter) match(e Entry) bool {
+	return (f.TenantId == "" || f.TenantId == e.TenantId) &&
+		(f.RequestId == "" || f.RequestId == e.RequestId) &&
+		(f.ConfigurationId == "" || f.ConfigurationId == e.ConfigurationId) &&
+		(f.Step == "" || f.Step == e.Step) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Query returns the matching entries in recording order, at most Limit of them if set. A trail with
+// file reads the entries recorded so far from it without blocking new entries and verifies their chain
+// again, so that entries changed in the file after Verify fail the query with ErrChainBroken.
+func (t *Trail) Query(f Filter) ([]Entry, error) {
+	if t == nil {
+		return []Entry{}, nil
+	}
+
+	t.mu.Lock()
+	if t.path == "" {
+		defer t.mu.Unlock()
+		return filter(slices.Values(t.recent), f), nil
+	}
+	size, last := t.size, t.last
+	t.mu.Unlock()
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(file, size))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		scanErr error
+		read    Entry
+	)
+	// entries continues with the next line when ranged over again
+	entries := func(yield func(Entry) bool) {
+		for scanner.Scan() {
+			var e Entry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				scanErr = fmt.Errorf("%w at entry %d: %w", ErrChainBroken, read.Seq+1, err)
+				return
+			}
+			if scanErr = verify([]Entry{e}, read.Seq, read.Hash); scanErr != nil {
+				return
+			}
+			read = e
+			if !yield(e) {
+				return
+			}
+		}
+		scanErr = scanner.Err()
+	}
+
+	result := filter(entries, f)
+	if scanErr == nil {
+		// the chain has to end in the entry last recorded, a rewritten chain does not
+		for range entries {
+		}
+	}
+	if scanErr == nil && read.Hash != last.Hash {
+		scanErr = fmt.Errorf("%w, entry %d is not the one recorded", ErrChainBroken, read.Seq)
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return result, nil
+}
+
+func filter(entries iter.Seq[Entry], f Filter) []Entry {
+	result := []Entry{}
+	for e := range entries {
+		if !f.match(e) {
+			continue
+		}
+		result = append(result, e)
+		if f.Limit > 0 && len(result) == f.Limit {
+			break
+		}
+	}
+	return result
+}
+
+func (t *Trail) Close() error {
+	if t == nil || t.file == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.file.Close()
+}
+
+// CredentialHash identifies a credential in the trail without recording its claims.
+func CredentialHash(credential any) string {
+	if credential == nil {
+		return ""
+	}
+
+	b, err := json.Marshal(credential)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,244 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func record(t *testing.T, trail *Trail, entries ...Entry) {
+	t.Helper()
+	for _, e := range entries {
+		if err := trail.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrailChain(t *testing.T) {
+	// entries t1 and t2 are verified, t3 is appended afterwards
+	tests := []struct {
+		name   string
+		tamper func(b []byte) []byte
+		// whether the incremental verification, reopening and queries detect the change
+		verifyBroken, openBroken, queryBroken bool
+	}{
+		{name: "intact"},
+		{
+			name: "changed verified entry",
+			tamper: func(b []byte) []byte {
+				return bytes.Replace(b, []byte(`"tenant_id":"t2"`), []byte(`"tenant_id":"tx"`), 1)
+			},
+			verifyBroken: false,
+			openBroken:   true,
+			queryBroken:  true,
+		},
+		{
+			name: "changed appended entry",
+			tamper: func(b []byte) []byte {
+				return bytes.Replace(b, []byte(`"tenant_id":"t3"`), []byte(`"tenant_id":"tx"`), 1)
+			},
+			verifyBroken: true,
+			openBroken:   true,
+			queryBroken:  true,
+		},
+		{
+			name:         "rewritten chain",
+			tamper:       rewrite("t2", "tx"),
+			verifyBroken: true,
+			openBroken:   false,
+			queryBroken:  true,
+		},
+		{
+			name:         "removed entry",
+			tamper:       func(b []byte) []byte { return b[bytes.IndexByte(b, '\n')+1:] },
+			verifyBroken: true,
+			openBroken:   true,
+			queryBroken:  true,
+		},
+		{
+			name:         "truncated",
+			tamper:       func(b []byte) []byte { return b[:bytes.IndexByte(b, '\n')+1] },
+			verifyBroken: true,
+			openBroken:   false,
+			queryBroken:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			trail, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer trail.Close()
+
+			record(t, trail, Entry{Step: StepRequestReceived, TenantId: "t1"}, Entry{Step: StepOfferObtained, TenantId: "t2"})
+			if err := trail.Verify(); err != nil {
+				t.Fatalf("Verify() of recorded entries: %v", err)
+			}
+
+			record(t, trail, Entry{Step: StepCredentialPrepared, TenantId: "t3"})
+
+			if tt.tamper != nil {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, tt.tamper(b), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := trail.Query(Filter{Limit: 1}); errors.Is(err, ErrChainBroken) != tt.queryBroken {
+				t.Errorf("Query() error = %v, want broken chain %v", err, tt.queryBroken)
+			}
+			if err := trail.Verify(); errors.Is(err, ErrChainBroken) != tt.verifyBroken {
+				t.Errorf("Verify() error = %v, want broken chain %v", err, tt.verifyBroken)
+			}
+			if _, err := Open(path); errors.Is(err, ErrChainBroken) != tt.openBroken {
+				t.Errorf("Open() error = %v, want broken chain %v", err, tt.openBroken)
+			}
+		})
+	}
+}
+
+// rewrite replaces the tenant in the trail and computes the chain again, as someone able to write the
+// file could do.
+func rewrite(tenant, to string) func(b []byte) []byte {
+	return func(b []byte) []byte {
+		var out []byte
+		prev := ""
+		for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				panic(err)
+			}
+			if e.TenantId == tenant {
+				e.TenantId = to
+			}
+			e.PrevHash = prev
+			e.Hash = e.digest()
+			prev = e.Hash
+			l, _ := json.Marshal(e)
+			out = append(append(out, l...), '\n')
+		}
+		return out
+	}
+}
+
+func TestTrailReopenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	trail, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(t, trail, Entry{Step: StepRequestReceived, TenantId: "t1"})
+	trail.Close()
+
+	trail, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trail.Close()
+	record(t, trail, Entry{Step: StepOfferObtained, TenantId: "t1"})
+
+	result := trail.Result(Filter{})
+	if !result.Valid {
+		t.Fatalf("chain of reopened trail is broken: %s", result.ChainError)
+	}
+	if len(result.Entries) != 2 || result.Entries[1].Seq != 2 || result.Entries[1].PrevHash != result.Entries[0].Hash {
+		t.Errorf("entries do not continue the chain: %+v", result.Entries)
+	}
+}
+
+func TestTrailQuery(t *testing.T) {
+	entries := []Entry{
+		{Step: StepRequestReceived, TenantId: "t1", RequestId: "r1"},
+		{Step: StepOfferObtained, TenantId: "t1", RequestId: "r1"},
+		{Step: StepRequestReceived, TenantId: "t2", RequestId: "r2"},
+		{Step: StepRequestFailed, TenantId: "t2", RequestId: "r2", Error: "policy-denied"},
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantSeq []uint64
+	}{
+		{name: "all", filter: Filter{}, wantSeq: []uint64{1, 2, 3, 4}},
+		{name: "tenant", filter: Filter{TenantId: "t2"}, wantSeq: []uint64{3, 4}},
+		{name: "step", filter: Filter{Step: StepRequestReceived}, wantSeq: []uint64{1, 3}},
+		{name: "limit", filter: Filter{Limit: 3}, wantSeq: []uint64{1, 2, 3}},
+		{name: "no match", filter: Filter{RequestId: "r3"}, wantSeq: []uint64{}},
+	}
+
+	for _, file := range []bool{false, true} {
+		path := ""
+		if file {
+			path = filepath.Join(t.TempDir(), "audit.jsonl")
+		}
+		trail, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record(t, trail, entries...)
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := trail.Query(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seqs := []uint64{}
+				for _, e := range got {
+					seqs = append(seqs, e.Seq)
+				}
+				if len(seqs) != len(tt.wantSeq) {
+					t.Fatalf("Query() (file %v) = %v, want %v", file, seqs, tt.wantSeq)
+				}
+				for i := range seqs {
+					if seqs[i] != tt.wantSeq[i] {
+						t.Fatalf("Query() (file %v) = %v, want %v", file, seqs, tt.wantSeq)
+					}
+				}
+			})
+		}
+		trail.Close()
+	}
+}
+
+func TestTrailMemoryWindow(t *testing.T) {
+	trail, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range memoryWindow + 5 {
+		record(t, trail, Entry{Step: StepRequestReceived, TenantId: "t1"})
+	}
+
+	result := trail.Result(Filter{Limit: 1})
+	if !result.Valid {
+		t.Fatalf("chain of the window is broken: %s", result.ChainError)
+	}
+	if len(trail.recent) != memoryWindow || result.Entries[0].Seq != 6 {
+		t.Errorf("window holds %d entries from %d, want %d from 6", len(trail.recent), result.Entries[0].Seq, memoryWindow)
+	}
+}
+
+func TestNilTrail(t *testing.T) {
+	var trail *Trail
+	if err := trail.Record(Entry{Step: StepRequestReceived}); err != nil {
+		t.Errorf("Record() error = %v", err)
+	}
+	if result := trail.Result(Filter{}); !result.Valid || len(result.Entries) != 0 {
+		t.Errorf("Result() = %+v", result)
+	}
+}
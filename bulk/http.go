@@ -0,0 +1,129 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+)
+
+// MaxUpload is the largest input file accepted.
+const MaxUpload = 32 << 20
+
+// Handler serves POST /bulk?tenant_id=&configuration_id=&format=csv|jsonl with the input file as body,
+// GET /bulk?tenant_id= listing the jobs of a tenant, GET /bulk/{id} with the progress of a job and
+// GET /bulk/{id}/results?format=jsonl|csv with the result of every finished row.
+func Handler(conf config.Config, m *Manager) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /bulk", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		format := q.Get("format")
+		if format == "" {
+			format = FormatJsonl
+			if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t == "text/csv" {
+				format = FormatCsv
+			}
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxUpload))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		status, err := m.Submit(conf, q.Get("tenant_id"), q.Get("configuration_id"), format, data)
+		if errors.Is(err, ErrBusy) {
+			w.Header().Set("Retry-After", "30")
+		}
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		writeJson(w, http.StatusAccepted, status)
+	})
+
+	mux.HandleFunc("GET /bulk", func(w http.ResponseWriter, r *http.Request) {
+		tenantId := r.URL.Query().Get("tenant_id")
+		if tenantId == "" {
+			http.Error(w, "tenant_id is required", http.StatusBadRequest)
+			return
+		}
+
+		list, err := m.List(tenantId)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("GET /bulk/{id}", func(w http.ResponseWriter, r *http.Request) {
+		status, err := m.Status(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		writeJson(w, http.StatusOK, status)
+	})
+
+	mux.HandleFunc("GET /bulk/{id}/results", func(w http.ResponseWriter, r *http.Request) {
+		results, err := m.Results(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		switch r.URL.Query().Get("format") {
+		case FormatJsonl, "":
+			w.Header().Set("Content-Type", "application/jsonl")
+			enc := json.NewEncoder(w)
+			for _, result := range results {
+				_ = enc.Encode(result)
+			}
+		case FormatCsv:
+			w.Header().Set("Content-Type", "text/csv")
+			cw := csv.NewWriter(w)
+			_ = cw.Write([]string{"row", "request_id", "offer", "error_id", "error"})
+			for _, result := range results {
+				var id, msg string
+				if result.Error != nil {
+					id, msg = result.Error.Id, result.Error.Msg
+				}
+				_ = cw.Write([]string{strconv.Itoa(result.Row), result.RequestId, result.Offer, id, msg})
+			}
+			cw.Flush()
+		default:
+			http.Error(w, "format must be jsonl or csv", http.StatusBadRequest)
+		}
+	})
+
+	return mux
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, metadata.ErrUnknownTenant):
+		return http.StatusNotFound
+	case errors.Is(err, metadata.ErrConfigurationDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrBusy):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
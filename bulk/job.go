@@ -0,0 +1,239 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eclipse-xfsc/nats-message-library/common"
+)
+
+// Formats of bulk input files.
+const (
+	FormatCsv   = "csv"
+	FormatJsonl = "jsonl"
+)
+
+// States of a job.
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	// the module stopped while the job was running, it resumes on the next start
+	StateInterrupted = "interrupted"
+)
+
+var (
+	ErrNotFound     = errors.New("no bulk job found")
+	ErrInvalidInput = errors.New("invalid bulk input")
+	ErrBusy         = errors.New("too many bulk jobs queued, retry later")
+)
+
+// Job describes a bulk issuance as stored next to its input and results.
+type Job struct {
+	Id              string    `json:"id"`
+	TenantId        string    `json:"tenant_id"`
+	ConfigurationId string    `json:"configuration_id"`
+	Format          string    `json:"format"`
+	Rows            int       `json:"rows"`
+	Created         time.Time `json:"created"`
+}
+
+// Status is a job with its progress.
+type Status struct {
+	Job
+	State     string `json:"state"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// Result is the outcome of one row, Row counts the payloads of the input from 1.
+type Result struct {
+	Row       int           `json:"row"`
+	RequestId string        `json:"request_id"`
+	Offer     string        `json:"offer,omitempty"`
+	Error     *common.Error `json:"error,omitempty"`
+}
+
+// requestId is derived from the job and row, so that a resumed row repeats the same request. The
+// idempotency window is kept in memory: it answers a row repeated by the same process, but a row whose
+// reply was lost in a shutdown may get a second offer after the restart.
+func (j Job) requestId(row int) string {
+	return fmt.Sprintf("%s-%d", j.Id, row)
+}
+
+// parse reads the payloads of the input. CSV files name the claims in their header row, every other
+// row is one payload of string claims. JSONL files hold one payload object per line, empty lines are skipped.
+func parse(format string, data []byte) ([]map[string]interface{}, error) {
+	switch format {
+	case FormatCsv:
+		return parseCsv(data)
+	case FormatJsonl:
+		return parseJsonl(data)
+	default:
+		return nil, fmt.Errorf("format must be %s or %s, got %q", FormatCsv, FormatJsonl, format)
+	}
+}
+
+func parseCsv(data []byte) ([]map[string]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads []map[string]interface{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return payloads, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(payloads)+1, err)
+		}
+
+		payload := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			if record[i] != "" {
+				payload[name] = record[i]
+			}
+		}
+		payloads = append(payloads, payload)
+	}
+}
+
+func parseJsonl(data []byte) ([]map[string]interface{}, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var payloads []map[string]interface{}
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal(line, &payload); err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(payloads)+1, err)
+		}
+		if payload == nil {
+			return nil, fmt.Errorf("row %d: payload must be an object", len(payloads)+1)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads, scanner.Err()
+}
+
+// store keeps every job in its own directory with job.json, the input and results.jsonl.
+type store struct {
+	dir string
+}
+
+func (s store) path(id string, name string) string {
+	return filepath.Join(s.dir, id, name)
+}
+
+func (s store) create(j Job, data []byte) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, j.Id), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path(j.Id, "input."+j.Format), data, 0o600); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	// job.json is written last, directories without it are incomplete uploads
+	return os.WriteFile(s.path(j.Id, "job.json"), b, 0o600)
+}
+
+func (s store) remove(id string) error {
+	return os.RemoveAll(filepath.Join(s.dir, id))
+}
+
+func (s store) jobs() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(s.path(e.Name(), "job.json"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var j Job
+		if err := json.Unmarshal(b, &j); err != nil {
+			return nil, fmt.Errorf("bulk job %s: %w", e.Name(), err)
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func (s store) input(j Job) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(s.path(j.Id, "input."+j.Format))
+	if err != nil {
+		return nil, err
+	}
+	return parse(j.Format, data)
+}
+
+// results returns the recorded results in the order they were recorded. A line cut off by an
+// interruption is ignored, its row runs again.
+func (s store) results(id string) ([]Result, error) {
+	f, err := os.Open(s.path(id, "results.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []Result
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Result
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, scanner.Err()
+}
+
+// openResults opens the results for appending and terminates a line cut off by an interruption.
+func (s store) openResults(id string) (*os.File, error) {
+	path := s.path(id, "results.jsonl")
+	if b, err := os.ReadFile(path); err == nil && len(b) > 0 && b[len(b)-1] != '\n' {
+		if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
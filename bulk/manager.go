@@ -0,0 +1,410 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/google/uuid"
+)
+
+// Requester sends an issuance request to .request and returns the reply.
+type Requester func(ctx context.Context, req messaging.IssuanceRequest) (messaging.IssuanceReply, error)
+
+// progress counts the results of a job.
+type progress struct {
+	running   bool
+	done      map[int]bool
+	succeeded int
+	failed    int
+}
+
+// Manager runs bulk jobs. Rows are sent with bounded parallelism across all jobs, each result is
+// appended to the results of its job as soon as it is known, so that an interrupted job continues
+// with the rows that have no result yet. A nil Manager has no jobs.
+type Manager struct {
+	store   store
+	request Requester
+	timeout time.Duration
+	slots   chan struct{}
+	queue   chan Job
+
+	mu       sync.Mutex
+	progress map[string]*progress
+}
+
+// New returns a Manager if BULK_DIR is set, nil otherwise.
+func New(conf config.Config, request Requester) *Manager {
+	if conf.Bulk.Dir == "" {
+		return nil
+	}
+
+	return &Manager{
+		store:    store{dir: conf.Bulk.Dir},
+		request:  request,
+		timeout:  conf.Nats.TimeoutInSec,
+		slots:    make(chan struct{}, conf.Bulk.Parallelism),
+		queue:    make(chan Job, 64),
+		progress: make(map[string]*progress),
+	}
+}
+
+// Submit validates the payloads and stores the job, it starts once Run picks it up.
+func (m *Manager) Submit(conf config.Config, tenantId string, configurationId string, format string, data []byte) (Status, error) {
+	if m == nil {
+		return Status{}, ErrNotFound
+	}
+
+	if _, err := metadata.Enabled(conf, tenantId, configurationId); err != nil {
+		return Status{}, err
+	}
+
+	payloads, err := parse(format, data)
+	if err != nil {
+		return Status{}, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	if len(payloads) == 0 {
+		return Status{}, fmt.Errorf("%w: %s has no payloads", ErrInvalidInput, format)
+	}
+
+	j := Job{
+		Id:              uuid.NewString(),
+		TenantId:        tenantId,
+		ConfigurationId: configurationId,
+		Format:          format,
+		Rows:            len(payloads),
+		Created:         time.Now().UTC(),
+	}
+	if err := m.store.create(j, data); err != nil {
+		return Status{}, err
+	}
+
+	p := &progress{running: true, done: map[int]bool{}}
+	m.mu.Lock()
+	m.progress[j.Id] = p
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- j:
+	default:
+		// the job would only start with the next resume, the client is asked to retry instead
+		slog.Warn("bulk job queue is full", logging.KeyTenantId, tenantId, "job_id", j.Id)
+		m.mu.Lock()
+		delete(m.progress, j.Id)
+		m.mu.Unlock()
+		if err := m.store.remove(j.Id); err != nil {
+			slog.Error("rejected bulk job could not be removed", "job_id", j.Id, "error", err)
+		}
+		return Status{}, ErrBusy
+	}
+	return m.Status(j.Id)
+}
+
+// Run resumes the jobs interrupted by the last shutdown and runs submitted jobs until ctx is done.
+// Rows in flight at that point are still answered and recorded, bounded by the NATS request timeout;
+// only rows whose request fails after ctx is done get no result and run again after the next start.
+func (m *Manager) Run(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	jobs, err := m.store.jobs()
+	if err != nil {
+		slog.Error("bulk jobs could not be loaded", "error", err)
+	}
+
+	var wg sync.WaitGroup
+	start := func(j Job) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.run(ctx, j)
+		}()
+	}
+
+	for _, j := range jobs {
+		m.mu.Lock()
+		_, submitted := m.progress[j.Id]
+		m.mu.Unlock()
+		if submitted {
+			continue
+		}
+
+		p, err := m.load(j)
+		if err != nil {
+			slog.Error("bulk job could not be resumed", "job_id", j.Id, "error", err)
+			continue
+		}
+		if len(p.done) < j.Rows {
+			slog.Info("resuming bulk job", logging.KeyTenantId, j.TenantId, "job_id", j.Id, "remaining", j.Rows-len(p.done))
+			start(j)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case j := <-m.queue:
+			start(j)
+		}
+	}
+}
+
+// load counts the recorded results of the job.
+func (m *Manager) load(j Job) (*progress, error) {
+	results, err := m.store.results(j.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &progress{done: map[int]bool{}}
+	for _, r := range results {
+		p.count(r)
+	}
+
+	m.mu.Lock()
+	m.progress[j.Id] = p
+	m.mu.Unlock()
+	return p, nil
+}
+
+func (p *progress) count(r Result) {
+	if p.done[r.Row] {
+		return
+	}
+	p.done[r.Row] = true
+	if r.Error != nil {
+		p.failed++
+	} else {
+		p.succeeded++
+	}
+}
+
+func (m *Manager) run(ctx context.Context, j Job) {
+	logger := slog.With(logging.KeyTenantId, j.TenantId, "job_id", j.Id, logging.KeyConfigurationId, j.ConfigurationId)
+
+	m.mu.Lock()
+	p := m.progress[j.Id]
+	p.running = true
+	done := make(map[int]bool, len(p.done))
+	for row := range p.done {
+		done[row] = true
+	}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		p.running = false
+		m.mu.Unlock()
+	}()
+
+	payloads, err := m.store.input(j)
+	if err != nil {
+		logger.Error("bulk job input could not be read", "error", err)
+		return
+	}
+
+	f, err := m.store.openResults(j.Id)
+	if err != nil {
+		logger.Error("bulk job results could not be opened", "error", err)
+		return
+	}
+	defer f.Close()
+
+	var wg sync.WaitGroup
+	for i, payload := range payloads {
+		row := i + 1
+		if done[row] {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case m.slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-m.slots }()
+
+			// the request is not cancelled with ctx, its offer would be lost with the reply
+			r, err := m.send(context.WithoutCancel(ctx), j, row, payload)
+			if errors.Is(err, errShuttingDown) || err != nil && ctx.Err() != nil {
+				logger.Warn("bulk row interrupted, it runs again after the next start", "row", row, "error", err)
+				return
+			}
+			if err := m.record(f, p, r); err != nil {
+				logger.Error("bulk job result could not be recorded", "row", row, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() == nil {
+		m.mu.Lock()
+		logger.Info("bulk job completed", "succeeded", p.succeeded, "failed", p.failed)
+		m.mu.Unlock()
+	}
+}
+
+// shuttingDown is the id of the error the module answers requests with once it is shutting down.
+const shuttingDown = "shutting-down"
+
+// errShuttingDown is returned by send for rows refused because the module is shutting down, they were
+// not handled and run again after the next start.
+var errShuttingDown = errors.New("refused while shutting down")
+
+// send requests the offer of the row. The error is that of the request, which is also set on the
+// result, or errShuttingDown.
+func (m *Manager) send(ctx context.Context, j Job, row int, payload map[string]interface{}) (Result, error) {
+	req := messaging.IssuanceRequest{
+		Request: common.Request{
+			TenantId:  j.TenantId,
+			RequestId: j.requestId(row),
+			GroupId:   j.Id,
+		},
+		Payload:    payload,
+		Identifier: j.ConfigurationId,
+	}
+	r := Result{Row: row, RequestId: req.RequestId}
+
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	reply, err := m.request(ctx, req)
+	switch {
+	case err != nil:
+		r.Error = &common.Error{
+			Id:     "bulk-request-error",
+			Status: 502,
+			Msg:    err.Error(),
+		}
+	case reply.Error != nil && reply.Error.Id == shuttingDown:
+		r.Error = reply.Error
+		err = errShuttingDown
+	case reply.Error != nil:
+		r.Error = reply.Error
+	default:
+		r.Offer = reply.Offer.CredentialOffer
+	}
+	return r, err
+}
+
+func (m *Manager) record(f *os.File, p *progress, r Result) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	p.count(r)
+	return nil
+}
+
+// Status returns the job with its progress.
+func (m *Manager) Status(id string) (Status, error) {
+	if m == nil {
+		return Status{}, ErrNotFound
+	}
+
+	jobs, err := m.store.jobs()
+	if err != nil {
+		return Status{}, err
+	}
+	i := slices.IndexFunc(jobs, func(j Job) bool { return j.Id == id })
+	if i < 0 {
+		return Status{}, ErrNotFound
+	}
+	return m.status(jobs[i]), nil
+}
+
+// List returns the jobs of the tenant, oldest first.
+func (m *Manager) List(tenantId string) ([]Status, error) {
+	if m == nil {
+		return []Status{}, nil
+	}
+
+	jobs, err := m.store.jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Status{}
+	for _, j := range jobs {
+		if j.TenantId == tenantId {
+			list = append(list, m.status(j))
+		}
+	}
+	slices.SortFunc(list, func(a, b Status) int {
+		return a.Created.Compare(b.Created)
+	})
+	return list, nil
+}
+
+func (m *Manager) status(j Job) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := Status{Job: j, State: StateInterrupted}
+	p, ok := m.progress[j.Id]
+	if !ok {
+		return s
+	}
+
+	s.Succeeded, s.Failed = p.succeeded, p.failed
+	switch {
+	case len(p.done) >= j.Rows:
+		s.State = StateCompleted
+	case p.running:
+		s.State = StateRunning
+	}
+	return s
+}
+
+// Results returns the result of every finished row ordered by row.
+func (m *Manager) Results(id string) ([]Result, error) {
+	if _, err := m.Status(id); err != nil {
+		return nil, err
+	}
+
+	results, err := m.store.results(id)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool, len(results))
+	unique := results[:0]
+	for _, r := range results {
+		if !seen[r.Row] {
+			seen[r.Row] = true
+			unique = append(unique, r)
+		}
+	}
+	slices.SortFunc(unique, func(a, b Result) int { return a.Row - b.Row })
+	return unique, nil
+}
@@ -0,0 +1,149 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+)
+
+func testConfig(t *testing.T) config.Config {
+	conf := config.Default()
+	conf.Bulk.Dir = t.TempDir()
+	conf.Bulk.Parallelism = 2
+	return conf
+}
+
+func TestSubmitFullQueue(t *testing.T) {
+	conf := testConfig(t)
+	m := New(conf, nil)
+
+	for i := 0; i < cap(m.queue); i++ {
+		if _, err := m.Submit(conf, "acme", metadata.Credential_Identifier, FormatJsonl, []byte(`{"n":1}`)); err != nil {
+			t.Fatalf("job %d: %v", i, err)
+		}
+	}
+
+	if _, err := m.Submit(conf, "acme", metadata.Credential_Identifier, FormatJsonl, []byte(`{"n":1}`)); !errors.Is(err, ErrBusy) {
+		t.Fatalf("Submit() error = %v, want %v", err, ErrBusy)
+	}
+	if got := errorStatus(ErrBusy); got != 503 {
+		t.Errorf("errorStatus() = %d, want 503", got)
+	}
+
+	entries, err := os.ReadDir(conf.Bulk.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != cap(m.queue) {
+		t.Errorf("%d jobs stored, want %d, the rejected job must not be kept", len(entries), cap(m.queue))
+	}
+}
+
+func TestReplyAfterShutdownIsRecorded(t *testing.T) {
+	conf := testConfig(t)
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	m := New(conf, func(ctx context.Context, req messaging.IssuanceRequest) (messaging.IssuanceReply, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return messaging.IssuanceReply{}, ctx.Err()
+		}
+		var reply messaging.IssuanceReply
+		reply.Offer.CredentialOffer = fmt.Sprintf("openid-credential-offer://?request=%s", req.RequestId)
+		return reply, nil
+	})
+
+	status, err := m.Submit(conf, "acme", metadata.Credential_Identifier, FormatJsonl, []byte("{\"n\":1}\n{\"n\":2}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.Run(ctx)
+	}()
+
+	<-started
+	<-started
+	cancel()
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	results, err := m.Results(status.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("%d results recorded, want 2", len(results))
+	}
+	for _, r := range results {
+		if r.Error != nil || r.Offer == "" {
+			t.Errorf("row %d recorded as %+v, want the offer", r.Row, r)
+		}
+	}
+}
+
+func TestRequestFailedAfterShutdownIsNotRecorded(t *testing.T) {
+	tests := []struct {
+		name string
+		// whether the module is shut down before the reply arrives
+		cancel bool
+		reply  messaging.IssuanceReply
+		err    error
+	}{
+		{name: "request failed", cancel: true, err: errors.New("connection closed")},
+		{
+			// the guard of the module refuses the request before the bulk job sees the shutdown
+			name:  "refused while shutting down",
+			reply: messaging.IssuanceReply{Reply: common.Reply{Error: &common.Error{Id: "shutting-down", Status: 503, Msg: "module is shutting down"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := testConfig(t)
+
+			// Run returns once the module shuts down, the refused request is answered well before
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			m := New(conf, func(context.Context, messaging.IssuanceRequest) (messaging.IssuanceReply, error) {
+				if tt.cancel {
+					cancel()
+				}
+				return tt.reply, tt.err
+			})
+
+			status, err := m.Submit(conf, "acme", metadata.Credential_Identifier, FormatJsonl, []byte(`{"n":1}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Run(ctx)
+
+			results, err := m.Results(status.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 0 {
+				t.Errorf("results %+v recorded, the row must run again after the next start", results)
+			}
+		})
+	}
+}
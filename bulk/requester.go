@@ -0,0 +1,96 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+)
+
+// NatsRequester sends the rows to the .request subject of their configuration like any other client,
+// so that policies, limits and idempotency apply to bulk jobs as well.
+type NatsRequester struct {
+	conf    config.Config
+	mu      sync.Mutex
+	clients map[string]*cloudeventprovider.CloudEventProviderClient
+}
+
+func NewNatsRequester(conf config.Config) *NatsRequester {
+	return &NatsRequester{
+		conf:    conf,
+		clients: make(map[string]*cloudeventprovider.CloudEventProviderClient),
+	}
+}
+
+func (n *NatsRequester) client(subject string) (*cloudeventprovider.CloudEventProviderClient, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if c, ok := n.clients[subject]; ok {
+		return c, nil
+	}
+
+	c, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: n.conf.Nats},
+		cloudeventprovider.ConnectionTypeReq,
+		subject,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	n.clients[subject] = c
+	return c, nil
+}
+
+// Request implements Requester.
+func (n *NatsRequester) Request(ctx context.Context, req messaging.IssuanceRequest) (messaging.IssuanceReply, error) {
+	var reply messaging.IssuanceReply
+
+	subject, err := metadata.Subject(n.conf, req.Identifier)
+	if err != nil {
+		return reply, err
+	}
+	c, err := n.client(subject + ".request")
+	if err != nil {
+		return reply, err
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return reply, err
+	}
+	ev, err := cloudeventprovider.NewEvent("test-issuer", "issuance", b)
+	if err != nil {
+		return reply, err
+	}
+
+	rep, err := c.RequestCtx(ctx, ev)
+	if err != nil {
+		return reply, err
+	}
+	if rep == nil {
+		return reply, errors.New("no reply from " + subject + ".request")
+	}
+	err = json.Unmarshal(rep.Data(), &reply)
+	return reply, err
+}
+
+func (n *NatsRequester) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var err error
+	for subject, c := range n.clients {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(n.clients, subject)
+	}
+	return err
+}
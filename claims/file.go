@@ -0,0 +1,57 @@
+package claims
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultKey = "subject_id"
+
+// fileProvider looks the claims up in a YAML or JSON file mapping subject ids to claims. The subject id
+// is the reference field named by Key. The file is read once at startup.
+type fileProvider struct {
+	key      string
+	subjects map[string]map[string]interface{}
+}
+
+func newFileProvider(c config.ClaimsConfig) (*fileProvider, error) {
+	if c.File == "" {
+		return nil, fmt.Errorf("file is required for type %s", TypeFile)
+	}
+
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &fileProvider{key: c.Key}
+	if p.key == "" {
+		p.key = defaultKey
+	}
+
+	// JSON is valid YAML
+	if err := yaml.Unmarshal(b, &p.subjects); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", c.File, err)
+	}
+
+	return p, nil
+}
+
+func (p *fileProvider) Claims(ctx context.Context, req Request) (map[string]interface{}, error) {
+	id, ok := req.Reference[p.key]
+	if !ok {
+		return nil, fmt.Errorf("reference has no field %q", p.key)
+	}
+
+	claims, ok := p.subjects[fmt.Sprint(id)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return maps.Clone(claims), nil
+}
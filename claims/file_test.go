@@ -0,0 +1,93 @@
+package claims
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func TestFileProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		key       string
+		reference map[string]any
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "yaml",
+			file:      "\"42\":\n  given_name: Erika\n",
+			reference: map[string]any{"subject_id": "42"},
+			want:      "Erika",
+		},
+		{
+			name:      "json with key",
+			file:      `{"42": {"given_name": "Erika"}}`,
+			key:       "employee",
+			reference: map[string]any{"employee": 42},
+			want:      "Erika",
+		},
+		{
+			name:      "unknown subject",
+			file:      `{"42": {"given_name": "Erika"}}`,
+			reference: map[string]any{"subject_id": "43"},
+			wantErr:   ErrNotFound,
+		},
+		{
+			name:      "reference without key",
+			file:      `{"42": {"given_name": "Erika"}}`,
+			reference: map[string]any{"employee": "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "subjects.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := newFileProvider(config.ClaimsConfig{File: path, Key: tt.key})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := p.Claims(t.Context(), Request{Reference: tt.reference})
+			if tt.want == "" {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Claims() = %v, %v, want error %v", claims, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Claims() error = %v", err)
+			}
+			if claims["given_name"] != tt.want {
+				t.Errorf("Claims() = %v, want given_name %s", claims, tt.want)
+			}
+
+			// callers may change the claims, the next lookup must not see it
+			claims["given_name"] = "changed"
+			if again, _ := p.Claims(t.Context(), Request{Reference: tt.reference}); again["given_name"] != tt.want {
+				t.Errorf("claims changed by the caller are returned again: %v", again)
+			}
+		})
+	}
+}
+
+func TestFileProviderInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subjects.yaml")
+	if err := os.WriteFile(path, []byte("- not a mapping"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"", filepath.Join(t.TempDir(), "missing.yaml"), path} {
+		if _, err := newFileProvider(config.ClaimsConfig{File: file}); err == nil {
+			t.Errorf("newFileProvider(%q) succeeded", file)
+		}
+	}
+}
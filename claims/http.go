@@ -0,0 +1,79 @@
+package claims
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// httpProvider posts the request as JSON to Url and expects the claims as JSON object. 404 means the
+// subject is unknown.
+type httpProvider struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHttpProvider(c config.ClaimsConfig) (*httpProvider, error) {
+	if c.Url == "" {
+		return nil, fmt.Errorf("url is required for type %s", TypeHttp)
+	}
+
+	return &httpProvider{
+		url:     c.Url,
+		headers: c.Headers,
+		client:  &http.Client{Timeout: timeout(c)},
+	}, nil
+}
+
+func (p *httpProvider) Claims(ctx context.Context, req Request) (map[string]interface{}, error) {
+	body, err := json.Marshal(map[string]any{
+		"tenant_id":        req.TenantId,
+		"request_id":       req.RequestId,
+		"configuration_id": req.ConfigurationId,
+		"reference":        req.Reference,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	for k, v := range p.headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	// the body is left out of the error, it may echo the personal data of the subject
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("claims provider answered %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("claims provider answered no json object: %w", err)
+	}
+	if claims == nil {
+		return nil, ErrNotFound
+	}
+
+	return claims, nil
+}
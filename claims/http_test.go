@@ -0,0 +1,99 @@
+package claims
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func TestHttpProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		timeout time.Duration
+		want    string
+		wantErr error
+		// the error must not contain the body
+		redacted bool
+	}{
+		{name: "resolved", status: http.StatusOK, body: `{"given_name":"Erika"}`, want: "Erika"},
+		{name: "unknown subject", status: http.StatusNotFound, wantErr: ErrNotFound},
+		{name: "null", status: http.StatusOK, body: `null`, wantErr: ErrNotFound},
+		{name: "no json object", status: http.StatusOK, body: `["Erika"]`},
+		{name: "failure", status: http.StatusInternalServerError, body: `{"given_name":"Erika"} not saved`, redacted: true},
+		{name: "timeout", timeout: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.timeout > 0 {
+					<-release
+					return
+				}
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			p, err := newHttpProvider(config.ClaimsConfig{
+				Url:     srv.URL,
+				Headers: map[string]string{"Authorization": "Bearer secret"},
+				Timeout: tt.timeout,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := p.Claims(t.Context(), Request{
+				TenantId:        "t1",
+				RequestId:       "r1",
+				ConfigurationId: "c1",
+				Reference:       map[string]any{"subject_id": "42"},
+			})
+			if tt.want != "" {
+				if err != nil {
+					t.Fatalf("Claims() error = %v", err)
+				}
+				if claims["given_name"] != tt.want {
+					t.Errorf("Claims() = %v, want given_name %s", claims, tt.want)
+				}
+				if ref, _ := got["reference"].(map[string]any); got["tenant_id"] != "t1" || got["configuration_id"] != "c1" || ref["subject_id"] != "42" {
+					t.Errorf("provider got %v", got)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Claims() = %v, want error", claims)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Claims() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.redacted && strings.Contains(err.Error(), "Erika") {
+				t.Errorf("Claims() error = %q contains the answer of the provider", err)
+			}
+		})
+	}
+}
+
+func TestHttpProviderWithoutUrl(t *testing.T) {
+	if _, err := newHttpProvider(config.ClaimsConfig{}); err == nil {
+		t.Error("newHttpProvider() without url succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+package claims
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Provider types.
+const (
+	TypeHttp = "http"
+	TypeSql  = "sql"
+	TypeFile = "file"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var ErrNotFound = errors.New("no claims found for the reference")
+
+// Request identifies the credential subject whose claims are needed. Reference is the payload of the
+// offer request, it holds the keys of the subject instead of its personal data.
+type Request struct {
+	TenantId        string
+	RequestId       string
+	ConfigurationId string
+	Reference       map[string]interface{}
+}
+
+// Provider resolves the claims of a credential subject from a system of record when the credential is picked up.
+type Provider interface {
+	Claims(ctx context.Context, req Request) (map[string]interface{}, error)
+}
+
+type entry struct {
+	tenant        string
+	configuration string
+	provider      Provider
+}
+
+// Resolver selects the provider of a tenant and credential configuration. A nil Resolver has no providers.
+type Resolver struct {
+	entries []entry
+}
+
+// New builds the providers configured in conf.Claims.
+func New(conf config.Config) (*Resolver, error) {
+	r := &Resolver{}
+
+	for i, c := range conf.Claims {
+		var p Provider
+		var err error
+
+		switch c.Type {
+		case TypeHttp:
+			p, err = newHttpProvider(c)
+		case TypeSql:
+			p, err = newSqlProvider(c)
+		case TypeFile:
+			p, err = newFileProvider(c)
+		default:
+			err = fmt.Errorf("unknown type %q", c.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("claims[%d]: %w", i, err)
+		}
+
+		r.entries = append(r.entries, entry{tenant: c.Tenant, configuration: c.Configuration, provider: p})
+	}
+
+	return r, nil
+}
+
+// For returns the provider of the configuration, one configured for the tenant before one for all tenants,
+// or nil if the claims are pushed with the offer request.
+func (r *Resolver) For(tenantId string, configurationId string) Provider {
+	if r == nil {
+		return nil
+	}
+
+	var fallback Provider
+	for _, e := range r.entries {
+		if e.configuration != configurationId {
+			continue
+		}
+		if e.tenant == tenantId {
+			return e.provider
+		}
+		if e.tenant == "" && fallback == nil {
+			fallback = e.provider
+		}
+	}
+	return fallback
+}
+
+func timeout(c config.ClaimsConfig) time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
@@ -0,0 +1,90 @@
+package claims
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// sqlProvider runs Query with the reference fields named in Parameters as arguments. The columns of
+// the first row become the claims.
+type sqlProvider struct {
+	db         *sql.DB
+	query      string
+	parameters []string
+	timeout    time.Duration
+}
+
+func newSqlProvider(c config.ClaimsConfig) (*sqlProvider, error) {
+	if c.Driver == "" || c.Dsn == "" || c.Query == "" {
+		return nil, fmt.Errorf("driver, dsn and query are required for type %s", TypeSql)
+	}
+
+	db, err := sql.Open(c.Driver, c.Dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlProvider{
+		db:         db,
+		query:      c.Query,
+		parameters: c.Parameters,
+		timeout:    timeout(c),
+	}, nil
+}
+
+func (p *sqlProvider) Claims(ctx context.Context, req Request) (map[string]interface{}, error) {
+	args := make([]any, 0, len(p.parameters))
+	for _, name := range p.parameters {
+		v, ok := req.Reference[name]
+		if !ok {
+			return nil, fmt.Errorf("reference has no field %q", name)
+		}
+		args = append(args, v)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, p.query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{}, len(columns))
+	for i, name := range columns {
+		if b, ok := values[i].([]byte); ok {
+			claims[name] = string(b)
+			continue
+		}
+		claims[name] = values[i]
+	}
+
+	return claims, errors.Join(rows.Close(), rows.Err())
+}
@@ -0,0 +1,134 @@
+package claims
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func init() {
+	sql.Register("claimstest", stubDriver{})
+}
+
+// stubDriver answers every query by the dsn it was opened with: "subject" has one row for the
+// argument "42", "slow" waits for the context and any other dsn fails.
+type stubDriver struct{}
+
+func (stubDriver) Open(dsn string) (driver.Conn, error) {
+	return stubConn{dsn: dsn}, nil
+}
+
+type stubConn struct {
+	dsn string
+}
+
+func (c stubConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch c.dsn {
+	case "subject":
+		rows := &stubRows{columns: []string{"given_name", "birthdate"}}
+		if len(args) == 1 && args[0].Value == "42" {
+			rows.values = [][]driver.Value{{[]byte("Erika"), "1964-08-12"}}
+		}
+		return rows, nil
+	case "slow":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("connection refused")
+}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (stubConn) Close() error { return nil }
+
+func (stubConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+type stubRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+
+func (r *stubRows) Close() error { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestSqlProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		dsn       string
+		reference map[string]any
+		want      map[string]any
+		wantErr   error
+	}{
+		{
+			name:      "resolved",
+			dsn:       "subject",
+			reference: map[string]any{"subject_id": "42"},
+			want:      map[string]any{"given_name": "Erika", "birthdate": "1964-08-12"},
+		},
+		{name: "unknown subject", dsn: "subject", reference: map[string]any{"subject_id": "43"}, wantErr: ErrNotFound},
+		{name: "reference without parameter", dsn: "subject", reference: map[string]any{"employee": "42"}},
+		{name: "timeout", dsn: "slow", reference: map[string]any{"subject_id": "42"}, wantErr: context.DeadlineExceeded},
+		{name: "failure", dsn: "broken", reference: map[string]any{"subject_id": "42"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newSqlProvider(config.ClaimsConfig{
+				Driver:     "claimstest",
+				Dsn:        tt.dsn,
+				Query:      "SELECT given_name, birthdate FROM employees WHERE id = $1",
+				Parameters: []string{"subject_id"},
+				Timeout:    50 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.db.Close()
+
+			claims, err := p.Claims(t.Context(), Request{Reference: tt.reference})
+			if tt.want == nil {
+				if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("Claims() = %v, %v, want error %v", claims, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Claims() error = %v", err)
+			}
+			if len(claims) != len(tt.want) {
+				t.Fatalf("Claims() = %v, want %v", claims, tt.want)
+			}
+			for k, v := range tt.want {
+				if claims[k] != v {
+					t.Errorf("Claims()[%s] = %#v, want %#v", k, claims[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestSqlProviderIncomplete(t *testing.T) {
+	if _, err := newSqlProvider(config.ClaimsConfig{Driver: "claimstest", Dsn: "subject"}); err == nil {
+		t.Error("newSqlProvider() without query succeeded")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/wallet"
+	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/google/uuid"
+)
+
+func credentialIssue(o *options, args []string) (any, error) {
+	var identifier, code, offer, holder, format string
+	o.flags.StringVar(&identifier, "identifier", metadata.Credential_Identifier, "credential configuration id")
+	o.flags.StringVar(&code, "code", "", "pre-authorized code of the offer")
+	o.flags.StringVar(&offer, "offer", "", "credential offer to take the pre-authorized code from, by value or by credential_offer_uri")
+	o.flags.StringVar(&holder, "holder", "", "DID of the holder")
+	o.flags.StringVar(&format, "format", "", "credential format, the format of the configuration if empty")
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+	if (code == "") == (offer == "") {
+		return nil, fmt.Errorf("%w: either -code or -offer is required", errUsage)
+	}
+
+	if offer != "" {
+		var err error
+		if code, err = preAuthorizedCode(offer, o); err != nil {
+			return nil, err
+		}
+	}
+
+	subject, err := o.subject(identifier, "issue")
+	if err != nil {
+		return nil, err
+	}
+
+	req := issumsg.IssuanceModuleReq{
+		Request: common.Request{
+			TenantId:  o.tenant,
+			RequestId: uuid.NewString(),
+		},
+		Code:   code,
+		Holder: holder,
+		Format: format,
+	}
+
+	// the reply is kept as is, it carries fields beyond IssuanceModuleRep such as notification_id
+	var reply map[string]any
+	err = o.request(subject, "issue", req, &reply)
+	return reply, err
+}
+
+// preAuthorizedCode returns the pre-authorized code of an offer passed by value or by reference.
+func preAuthorizedCode(offer string, o *options) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	params, err := wallet.ResolveOffer(ctx, http.DefaultClient, offer)
+	if err != nil {
+		return "", err
+	}
+	return wallet.PreAuthorizedCode(params)
+}
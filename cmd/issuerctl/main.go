@@ -0,0 +1,218 @@
+// Command issuerctl operates a running issuance module over NATS: it creates offers, issues and
+// revokes credentials, shows the published metadata and stored offers and redeems offers with a
+// simulated wallet. Replies are written to stdout as JSON, diagnostics to stderr.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+)
+
+// Exit codes.
+const (
+	exitOk = 0
+	// the module answered with an error
+	exitReplyError = 1
+	exitUsage      = 2
+	// the module could not be reached or the reply could not be read
+	exitFailure = 3
+)
+
+var errUsage = errors.New("invalid arguments")
+
+type command struct {
+	name  string
+	usage string
+	run   func(o *options, args []string) (any, error)
+}
+
+var commands = []command{
+	{"offer create", "-identifier ID -payload FILE [-request-id ID] [-recipient ADDRESS -locale LOCALE]", offerCreate},
+	{"credential issue", "-code CODE | -offer OFFER [-holder DID] [-format FORMAT]", credentialIssue},
+	{"metadata show", "[-timeout 45s]", metadataShow},
+	{"storage list", "[-status STATUS]", storageList},
+	{"status revoke", "-notification-id ID [-reason REASON]", statusRevoke},
+	{"wallet redeem", "-offer OFFER (-keys JWKS | -trust-did DID,...) [-encrypt]", walletRedeem},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: issuerctl <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-17s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\ncommon flags: -nats URL -tenant ID -subject-prefix PREFIX -timeout DURATION")
+	fmt.Fprintln(os.Stderr, "\nexit codes: 0 ok, 1 error reply, 2 usage, 3 no or unreadable reply")
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout))
+}
+
+// run executes the command of args, writes its reply to stdout and returns the exit code.
+func run(args []string, stdout io.Writer) int {
+	if len(args) < 2 {
+		usage()
+		return exitUsage
+	}
+
+	name := args[0] + " " + args[1]
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		o := &options{}
+		o.register(fs)
+		o.flags = fs
+
+		reply, err := c.run(o, args[2:])
+		if errors.Is(err, errUsage) {
+			if err != errUsage {
+				fmt.Fprintln(os.Stderr, "issuerctl:", err)
+			}
+			fmt.Fprintf(os.Stderr, "usage: issuerctl %s %s\n", c.name, c.usage)
+			return exitUsage
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "issuerctl:", err)
+			return exitFailure
+		}
+
+		b, err := json.MarshalIndent(reply, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "issuerctl:", err)
+			return exitFailure
+		}
+		fmt.Fprintln(stdout, string(b))
+
+		if failed(reply) {
+			return exitReplyError
+		}
+		return exitOk
+	}
+
+	usage()
+	return exitUsage
+}
+
+// failed reports whether the reply carries an error.
+func failed(reply any) bool {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return true
+	}
+	var r struct {
+		Error *common.Error `json:"error"`
+	}
+	return json.Unmarshal(b, &r) == nil && r.Error != nil
+}
+
+// options are the flags shared by all commands.
+type options struct {
+	flags   *flag.FlagSet
+	nats    string
+	tenant  string
+	prefix  string
+	timeout time.Duration
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.nats, "nats", env("NATS_URL", "nats://localhost:4222"), "NATS url")
+	fs.StringVar(&o.tenant, "tenant", env("TENANT", metadata.DefaultTenant), "tenant id")
+	fs.StringVar(&o.prefix, "subject-prefix", env("SUBJECT_PREFIX", ""), "prefix of the subjects of the module")
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second, "time to wait for the reply")
+}
+
+func (o *options) parse(args []string) error {
+	if err := o.flags.Parse(args); err != nil {
+		// the flag set has reported the error
+		return errUsage
+	}
+	if o.flags.NArg() > 0 {
+		return fmt.Errorf("%w: unexpected arguments %v", errUsage, o.flags.Args())
+	}
+	return nil
+}
+
+func env(key string, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
+
+// subject is the subject of the operation on the credential configuration.
+func (o *options) subject(identifier string, operation string) (string, error) {
+	subject, err := metadata.Subject(config.Config{SubjectPrefix: o.prefix}, identifier)
+	if err != nil {
+		return "", err
+	}
+	return subject + "." + operation, nil
+}
+
+// request sends req to the subject and decodes the reply into reply.
+func (o *options) request(subject string, eventType string, req any, reply any) error {
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: cloudeventprovider.NatsConfig{
+			Url:          o.nats,
+			TimeoutInSec: o.timeout,
+		}},
+		cloudeventprovider.ConnectionTypeReq,
+		subject,
+	)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ev, err := cloudeventprovider.NewEvent("issuerctl", eventType, b)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	rep, err := client.RequestCtx(ctx, ev)
+	if err != nil {
+		return fmt.Errorf("%s: %w", subject, err)
+	}
+	if rep == nil {
+		return fmt.Errorf("%s: no reply", subject)
+	}
+	return json.Unmarshal(rep.Data(), reply)
+}
+
+// required fails with a usage error if one of the named flags is empty.
+func required(flags map[string]string) error {
+	var missing []string
+	for name, value := range flags {
+		if value == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("%w: %s required", errUsage, strings.Join(missing, ", "))
+	}
+	return nil
+}
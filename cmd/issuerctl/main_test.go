@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/fake"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	env, err := fake.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Close()
+	if err := env.StartModule(ctx, issuance.Services{Storage: issuance.NewDummyStorage(env.Config.Storage.TTL)}); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := filepath.Join(t.TempDir(), "claims.json")
+	if err := os.WriteFile(payload, []byte(`{"given_name":"Jane","family_name":"Doe"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	nats := []string{"-nats", env.Nats.Url(), "-timeout", "5s"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		// top-level field of the reply written to stdout
+		wantField string
+	}{
+		{name: "offer created", args: append([]string{"offer", "create", "-payload", payload}, nats...), wantCode: exitOk, wantField: "offer"},
+		{name: "offers listed", args: append([]string{"storage", "list", "-status", "prepared"}, nats...), wantCode: exitOk, wantField: "offers"},
+		{name: "unknown code", args: append([]string{"credential", "issue", "-code", "unknown"}, nats...), wantCode: exitReplyError, wantField: "error"},
+		{name: "unknown notification", args: append([]string{"status", "revoke", "-notification-id", "unknown"}, nats...), wantCode: exitReplyError, wantField: "error"},
+		{name: "no command", args: nil, wantCode: exitUsage},
+		{name: "unknown command", args: []string{"offer", "delete"}, wantCode: exitUsage},
+		{name: "missing flag", args: append([]string{"offer", "create"}, nats...), wantCode: exitUsage},
+		{name: "unknown flag", args: []string{"storage", "list", "-all"}, wantCode: exitUsage},
+		{name: "unexpected argument", args: []string{"storage", "list", "prepared"}, wantCode: exitUsage},
+		{name: "no module", args: []string{"storage", "list", "-nats", "nats://127.0.0.1:1", "-timeout", "1s"}, wantCode: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if code := run(tt.args, &stdout); code != tt.wantCode {
+				t.Fatalf("run() = %d, want %d, stdout: %s", code, tt.wantCode, stdout.String())
+			}
+
+			if tt.wantField == "" {
+				if stdout.Len() != 0 {
+					t.Errorf("stdout = %s, want nothing", stdout.String())
+				}
+				return
+			}
+			var reply map[string]any
+			if err := json.Unmarshal(stdout.Bytes(), &reply); err != nil {
+				t.Fatalf("stdout is no JSON object: %v: %s", err, stdout.String())
+			}
+			if reply[tt.wantField] == nil {
+				t.Errorf("reply has no %s: %s", tt.wantField, stdout.String())
+			}
+		})
+	}
+}
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		reply any
+		want  bool
+	}{
+		{reply: issuance.OfferReply{}, want: false},
+		{reply: map[string]any{"error": map[string]any{"id": "offer-closed"}}, want: true},
+		{reply: map[string]any{"error": nil}, want: false},
+		{reply: func() {}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := failed(tt.reply); got != tt.want {
+			t.Errorf("failed(%#v) = %v, want %v", tt.reply, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+)
+
+// metadataShow waits for the next registration of the tenant, the module publishes them every 30 seconds.
+func metadataShow(o *options, args []string) (any, error) {
+	o.timeout = 45 * time.Second
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: cloudeventprovider.NatsConfig{
+			Url:          o.nats,
+			TimeoutInSec: o.timeout,
+		}},
+		cloudeventprovider.ConnectionTypeSub,
+		messaging.TopicIssuerRegistration,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	found := make(chan messaging.IssuerRegistration, 1)
+	go func() {
+		_ = client.SubCtx(ctx, func(ev event.Event) {
+			var r messaging.IssuerRegistration
+			if json.Unmarshal(ev.Data(), &r) != nil || r.TenantId != o.tenant {
+				return
+			}
+			select {
+			case found <- r:
+			default:
+			}
+		})
+	}()
+
+	select {
+	case r := <-found:
+		return r, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no registration of tenant %s published within %s", o.tenant, o.timeout)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/google/uuid"
+)
+
+// offerRequest is an issuance request with the optional email delivery of the offer.
+type offerRequest struct {
+	messaging.IssuanceRequest
+	Recipient string `json:"recipient,omitempty"`
+	Locale    string `json:"locale,omitempty"`
+}
+
+func offerCreate(o *options, args []string) (any, error) {
+	var identifier, payload, requestId, groupId, recipient, locale string
+	o.flags.StringVar(&identifier, "identifier", metadata.Credential_Identifier, "credential configuration id")
+	o.flags.StringVar(&payload, "payload", "", "file with the claims as JSON object, - reads stdin")
+	o.flags.StringVar(&requestId, "request-id", uuid.NewString(), "request id, repeating it within the idempotency window returns the same offer")
+	o.flags.StringVar(&groupId, "group-id", "", "group id")
+	o.flags.StringVar(&recipient, "recipient", "", "email address the offer is sent to")
+	o.flags.StringVar(&locale, "locale", "", "locale of the email")
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"identifier": identifier, "payload": payload}); err != nil {
+		return nil, err
+	}
+
+	claims, err := readPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := o.subject(identifier, "request")
+	if err != nil {
+		return nil, err
+	}
+
+	req := offerRequest{
+		IssuanceRequest: messaging.IssuanceRequest{
+			Request: common.Request{
+				TenantId:  o.tenant,
+				RequestId: requestId,
+				GroupId:   groupId,
+			},
+			Payload:    claims,
+			Identifier: identifier,
+		},
+		Recipient: recipient,
+		Locale:    locale,
+	}
+
+	var reply messaging.IssuanceReply
+	err = o.request(subject, "issuance", req, &reply)
+	return reply, err
+}
+
+// readPayload reads a JSON object from the file or, for -, from stdin.
+func readPayload(name string) (map[string]interface{}, error) {
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, fmt.Errorf("payload %s: %w", name, err)
+	}
+	if payload == nil {
+		return nil, fmt.Errorf("payload %s must be a JSON object", name)
+	}
+	return payload, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/google/uuid"
+)
+
+func statusRevoke(o *options, args []string) (any, error) {
+	var identifier, notificationId, reason string
+	o.flags.StringVar(&identifier, "identifier", metadata.Credential_Identifier, "credential configuration id")
+	o.flags.StringVar(&notificationId, "notification-id", "", "notification id of the issued credential")
+	o.flags.StringVar(&reason, "reason", "", "reason of the revocation")
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"identifier": identifier, "notification-id": notificationId}); err != nil {
+		return nil, err
+	}
+
+	subject, err := o.subject(identifier, "revoke")
+	if err != nil {
+		return nil, err
+	}
+
+	req := issuance.RevocationRequest{
+		Request: common.Request{
+			TenantId:  o.tenant,
+			RequestId: uuid.NewString(),
+		},
+		NotificationId: notificationId,
+		Reason:         reason,
+	}
+
+	var reply common.Reply
+	err = o.request(subject, "revocation", req, &reply)
+	return reply, err
+}
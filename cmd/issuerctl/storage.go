@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+	"github.com/google/uuid"
+)
+
+func storageList(o *options, args []string) (any, error) {
+	var topic, status string
+	o.flags.StringVar(&topic, "offers-topic", env("OFFERS_TOPIC", "issuer.dummycontentsigner.offers"), "topic of the offer management")
+	o.flags.StringVar(&status, "status", "", "only list records with this status, e.g. prepared, issued, expired")
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+
+	req := issuance.OfferRequest{
+		Request: common.Request{
+			TenantId:  o.tenant,
+			RequestId: uuid.NewString(),
+		},
+		Operation: issuance.OfferOpList,
+		Status:    status,
+	}
+
+	var reply issuance.OfferReply
+	err := o.request(topic, "offer", req, &reply)
+	return reply, err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/wallet"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// redeemReply is the credential picked up by the simulated wallet, Error is set if the module
+// refused the offer or the credential failed verification.
+type redeemReply struct {
+	Credential *wallet.Credential `json:"credential,omitempty"`
+	Error      *common.Error      `json:"error,omitempty"`
+}
+
+func walletRedeem(o *options, args []string) (any, error) {
+	var offer, keys, dids string
+	var encrypt bool
+	o.flags.StringVar(&offer, "offer", "", "credential offer, by value or by credential_offer_uri")
+	o.flags.StringVar(&keys, "keys", "", "JWK set file with the trusted issuer keys")
+	o.flags.StringVar(&dids, "trust-did", "", "comma separated did:jwk of trusted issuers")
+	o.flags.BoolVar(&encrypt, "encrypt", false, "request the credential response encrypted")
+	if err := o.parse(args); err != nil {
+		return nil, err
+	}
+	if err := required(map[string]string{"offer": offer}); err != nil {
+		return nil, err
+	}
+	if keys == "" && dids == "" {
+		return nil, fmt.Errorf("%w: -keys or -trust-did required, credentials verify against trusted issuer keys only", errUsage)
+	}
+
+	conf := wallet.Config{
+		Nats:          cloudeventprovider.NatsConfig{Url: o.nats, TimeoutInSec: o.timeout},
+		SubjectPrefix: o.prefix,
+		TenantId:      o.tenant,
+		Encrypt:       encrypt,
+	}
+	if keys != "" {
+		b, err := os.ReadFile(keys)
+		if err != nil {
+			return nil, err
+		}
+		set, err := jwk.Parse(b)
+		if err != nil {
+			return nil, err
+		}
+		conf.Keys = wallet.KeySet(set)
+	}
+	if dids != "" {
+		conf.Keys = wallet.AnyOf(conf.Keys, wallet.TrustDids(strings.Split(dids, ",")...))
+	}
+
+	w, err := wallet.New(conf)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	c, err := w.Redeem(ctx, offer)
+	var replyErr *wallet.ReplyError
+	switch {
+	case errors.As(err, &replyErr):
+		return redeemReply{Error: replyErr.Reply}, nil
+	case c != nil && err != nil:
+		return redeemReply{Credential: c, Error: &common.Error{
+			Id:     "verification-failed",
+			Status: 422,
+			Msg:    err.Error(),
+		}}, nil
+	case err != nil:
+		return nil, err
+	}
+	return redeemReply{Credential: c}, nil
+}
@@ -1,13 +1,289 @@
 package config
 
-import cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+import (
+	"time"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+)
 
 type Config struct {
-	Nats                 cloudeventprovider.NatsConfig `envconfig:"NATS"`
-	Origin               string                        `envconfig:"ORIGIN"`
-	SignerUrl            string                        `envconfig:"SIGNERURL"`
-	SignerKey            string                        `envconfig:"SIGNERKEY"`
-	Credential_Issuer    string                        `envconfig:"CREDENTIAL_ISSUER"`
-	Authorization_Server []string                      `envconfig:"AUTHORIZATION_SERVER"`
-	Credential_Endpoint  string                        `envconfig:"CREDENTIAL_ENDPOINT"`
+	Nats                 cloudeventprovider.NatsConfig `envconfig:"NATS" yaml:"nats"`
+	Origin               string                        `envconfig:"ORIGIN" yaml:"origin"`
+	SignerUrl            string                        `envconfig:"SIGNERURL" yaml:"signerUrl"`
+	SignerKey            string                        `envconfig:"SIGNERKEY" yaml:"signerKey"`
+	Signer               SignerConfig                  `envconfig:"SIGNER" yaml:"signer"`
+	Credential_Issuer    string                        `envconfig:"CREDENTIAL_ISSUER" yaml:"credentialIssuer"`
+	Authorization_Server []string                      `envconfig:"AUTHORIZATION_SERVER" yaml:"authorizationServer"`
+	Credential_Endpoint  string                        `envconfig:"CREDENTIAL_ENDPOINT" yaml:"credentialEndpoint"`
+	SubjectPrefix        string                        `envconfig:"SUBJECT_PREFIX" yaml:"subjectPrefix"`
+	Tenants              []TenantConfig                `ignored:"true" yaml:"tenants"`
+	LogLevel             string                        `envconfig:"LOG_LEVEL" yaml:"logLevel"`
+	ShutdownTimeout      time.Duration                 `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdownTimeout"`
+	Storage              StorageConfig                 `envconfig:"STORAGE" yaml:"storage"`
+	Events               EventsConfig                  `envconfig:"EVENTS" yaml:"events"`
+	Http                 HttpConfig                    `envconfig:"HTTP" yaml:"http"`
+	Audit                AuditConfig                   `envconfig:"AUDIT" yaml:"audit"`
+	Idempotency          IdempotencyConfig             `envconfig:"IDEMPOTENCY" yaml:"idempotency"`
+	Limits               LimitsConfig                  `envconfig:"LIMITS" yaml:"limits"`
+	Claims               []ClaimsConfig                `ignored:"true" yaml:"claims"`
+	Mappings             []MappingConfig               `ignored:"true" yaml:"mappings"`
+	Policy               PolicyConfig                  `envconfig:"POLICY" yaml:"policy"`
+	Approval             ApprovalConfig                `envconfig:"APPROVAL" yaml:"approval"`
+	Offers               OffersConfig                  `envconfig:"OFFERS" yaml:"offers"`
+	Bulk                 BulkConfig                    `envconfig:"BULK" yaml:"bulk"`
+	Mail                 MailConfig                    `envconfig:"MAIL" yaml:"mail"`
+}
+
+type SignerConfig struct {
+	Timeout          time.Duration `envconfig:"TIMEOUT" yaml:"timeout"`
+	Retries          int           `envconfig:"RETRIES" yaml:"retries"`
+	RetryBackoff     time.Duration `envconfig:"RETRY_BACKOFF" yaml:"retryBackoff"`
+	BreakerThreshold int           `envconfig:"BREAKER_THRESHOLD" yaml:"breakerThreshold"`
+	BreakerCooldown  time.Duration `envconfig:"BREAKER_COOLDOWN" yaml:"breakerCooldown"`
+	MaxIdleConns     int           `envconfig:"MAX_IDLE_CONNS" yaml:"maxIdleConns"`
+	CertFile         string        `envconfig:"CERT_FILE" yaml:"certFile"`
+	KeyFile          string        `envconfig:"KEY_FILE" yaml:"keyFile"`
+	CAFile           string        `envconfig:"CA_FILE" yaml:"caFile"`
+	TokenUrl         string        `envconfig:"TOKEN_URL" yaml:"tokenUrl"`
+	ClientId         string        `envconfig:"CLIENT_ID" yaml:"clientId"`
+	ClientSecret     string        `envconfig:"CLIENT_SECRET" yaml:"clientSecret"`
+	Scopes           []string      `envconfig:"SCOPES" yaml:"scopes"`
+}
+
+// TenantConfig is the issuer metadata of one tenant. Empty fields fall back to the top level settings
+// and the built-in metadata, an empty Configurations list enables every credential configuration.
+type TenantConfig struct {
+	Id                   string             `yaml:"id"`
+	CredentialIssuer     string             `yaml:"credentialIssuer"`
+	AuthorizationServers []string           `yaml:"authorizationServers"`
+	CredentialEndpoint   string             `yaml:"credentialEndpoint"`
+	Display              []DisplayConfig    `yaml:"display"`
+	Configurations       []string           `yaml:"configurations"`
+	Keys                 []KeyConfig        `yaml:"keys"`
+	RequireEncryption    bool               `yaml:"requireEncryption"`
+	Limits               TenantLimitsConfig `yaml:"limits"`
+	Approvals            []string           `yaml:"approvals"`
+}
+
+// KeyConfig maps a credential configuration of a tenant to a signer key. An empty Configuration
+// makes it the default key of the tenant, an empty Namespace uses the tenant id.
+type KeyConfig struct {
+	Configuration string `yaml:"configuration"`
+	Namespace     string `yaml:"namespace"`
+	Group         string `yaml:"group"`
+	Key           string `yaml:"key"`
+	Algorithm     string `yaml:"algorithm"`
+}
+
+type DisplayConfig struct {
+	Name   string `yaml:"name"`
+	Locale string `yaml:"locale"`
+}
+
+type StorageConfig struct {
+	Type           string        `envconfig:"TYPE" yaml:"type"`
+	TTL            time.Duration `envconfig:"TTL" yaml:"ttl"`
+	ExpiryInterval time.Duration `envconfig:"EXPIRY_INTERVAL" yaml:"expiryInterval"`
+	Retention      time.Duration `envconfig:"RETENTION" yaml:"retention"`
+}
+
+const StorageTypeMemory = "memory"
+
+// EventsConfig names the NATS subjects of the emitted CloudEvents, an empty subject disables the event.
+type EventsConfig struct {
+	NotificationTopic      string `envconfig:"NOTIFICATION_TOPIC" yaml:"notificationTopic"`
+	OfferCreatedTopic      string `envconfig:"OFFER_CREATED_TOPIC" yaml:"offerCreatedTopic"`
+	OfferExpiredTopic      string `envconfig:"OFFER_EXPIRED_TOPIC" yaml:"offerExpiredTopic"`
+	OfferCancelledTopic    string `envconfig:"OFFER_CANCELLED_TOPIC" yaml:"offerCancelledTopic"`
+	CredentialIssuedTopic  string `envconfig:"CREDENTIAL_ISSUED_TOPIC" yaml:"credentialIssuedTopic"`
+	CredentialFailedTopic  string `envconfig:"CREDENTIAL_FAILED_TOPIC" yaml:"credentialFailedTopic"`
+	CredentialRevokedTopic string `envconfig:"CREDENTIAL_REVOKED_TOPIC" yaml:"credentialRevokedTopic"`
+}
+
+// HttpConfig sets the listeners. The administrative routes are served on AdminAddr to callers sending
+// one of the AdminTokens as bearer token, keyed by the caller name recorded as approver. Without tokens
+// the administrative routes are disabled.
+type HttpConfig struct {
+	Addr        string            `envconfig:"ADDR" yaml:"addr"`
+	AdminAddr   string            `envconfig:"ADMIN_ADDR" yaml:"adminAddr"`
+	AdminTokens map[string]string `envconfig:"ADMIN_TOKENS" yaml:"adminTokens"`
+}
+
+// AuditConfig locates the audit trail. An empty File keeps the latest entries in memory only, an empty
+// QueryTopic disables queries via NATS.
+type AuditConfig struct {
+	File       string `envconfig:"FILE" yaml:"file"`
+	QueryTopic string `envconfig:"QUERY_TOPIC" yaml:"queryTopic"`
+}
+
+// IdempotencyConfig sets how long offer requests are de-duplicated by tenant and request id, zero disables it.
+type IdempotencyConfig struct {
+	Window time.Duration `envconfig:"WINDOW" yaml:"window"`
+}
+
+// LimitsConfig limits the .request and .issue handlers, zero rates and quotas are unlimited.
+// UsageTopic names the NATS subject answering usage queries, empty disables it.
+type LimitsConfig struct {
+	Request    RateConfig `envconfig:"REQUEST" yaml:"request"`
+	Issue      RateConfig `envconfig:"ISSUE" yaml:"issue"`
+	DailyQuota int        `envconfig:"DAILY_QUOTA" yaml:"dailyQuota"`
+	UsageTopic string     `envconfig:"USAGE_TOPIC" yaml:"usageTopic"`
+}
+
+// TenantLimitsConfig overrides the limits for one tenant, zero values keep the global setting.
+type TenantLimitsConfig struct {
+	Request    RateConfig `yaml:"request"`
+	Issue      RateConfig `yaml:"issue"`
+	DailyQuota int        `yaml:"dailyQuota"`
+}
+
+// RateConfig sets token buckets in requests per second, the bursts default to the rounded up rate.
+type RateConfig struct {
+	TenantRate         float64 `envconfig:"TENANT_RATE" yaml:"tenantRate"`
+	TenantBurst        int     `envconfig:"TENANT_BURST" yaml:"tenantBurst"`
+	ConfigurationRate  float64 `envconfig:"CONFIGURATION_RATE" yaml:"configurationRate"`
+	ConfigurationBurst int     `envconfig:"CONFIGURATION_BURST" yaml:"configurationBurst"`
+}
+
+// ClaimsConfig resolves the credential subject of a configuration from a system of record when the
+// credential is picked up. An empty Tenant applies to every tenant.
+type ClaimsConfig struct {
+	Configuration string            `yaml:"configuration"`
+	Tenant        string            `yaml:"tenant"`
+	Type          string            `yaml:"type"`
+	Timeout       time.Duration     `yaml:"timeout"`
+	Url           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	Driver        string            `yaml:"driver"`
+	Dsn           string            `yaml:"dsn"`
+	Query         string            `yaml:"query"`
+	Parameters    []string          `yaml:"parameters"`
+	File          string            `yaml:"file"`
+	Key           string            `yaml:"key"`
+}
+
+// MappingConfig transforms the claims of a configuration before they are stored, or after they are
+// resolved for claims read at pickup. An empty Tenant applies to every tenant.
+type MappingConfig struct {
+	Configuration string       `yaml:"configuration"`
+	Tenant        string       `yaml:"tenant"`
+	Rules         []RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig is one mapping step, the fields used depend on Op. Claims are addressed by dotted paths.
+type RuleConfig struct {
+	Op        string      `yaml:"op"`
+	From      string      `yaml:"from"`
+	To        string      `yaml:"to"`
+	Fields    []string    `yaml:"fields"`
+	Value     interface{} `yaml:"value"`
+	Separator string      `yaml:"separator"`
+	Layout    string      `yaml:"layout"`
+	Format    string      `yaml:"format"`
+	Expr      string      `yaml:"expr"`
+}
+
+// PolicyConfig names the policy files evaluated on every .request and .issue, none allows everything.
+type PolicyConfig struct {
+	Files []string `envconfig:"FILES" yaml:"files"`
+}
+
+// ApprovalConfig lists the credential configurations whose prepared credentials must be approved before
+// they can be picked up. Topic names the NATS subject of approval decisions, empty disables it.
+type ApprovalConfig struct {
+	Configurations []string `envconfig:"CONFIGURATIONS" yaml:"configurations"`
+	Topic          string   `envconfig:"TOPIC" yaml:"topic"`
+}
+
+// OffersConfig names the NATS subject of offer status, list, cancel and regenerate requests, empty disables it.
+// With ByReference offers are returned as credential_offer_uri below BaseUrl, the public url of the
+// HTTP endpoint.
+type OffersConfig struct {
+	Topic       string `envconfig:"TOPIC" yaml:"topic"`
+	ByReference bool   `envconfig:"BY_REFERENCE" yaml:"byReference"`
+	BaseUrl     string `envconfig:"BASE_URL" yaml:"baseUrl"`
+}
+
+// BulkConfig enables bulk issuance jobs, which are kept in Dir so that they resume after a restart.
+// Parallelism bounds the offer requests in flight across all jobs.
+type BulkConfig struct {
+	Dir         string `envconfig:"DIR" yaml:"dir"`
+	Parallelism int    `envconfig:"PARALLELISM" yaml:"parallelism"`
+}
+
+// MailConfig enables delivery of offers by email if Host is set. Templates is the directory of the
+// per-tenant, per-locale templates, empty uses the built-in template.
+type MailConfig struct {
+	Host          string        `envconfig:"HOST" yaml:"host"`
+	Port          int           `envconfig:"PORT" yaml:"port"`
+	Security      string        `envconfig:"SECURITY" yaml:"security"`
+	Username      string        `envconfig:"USERNAME" yaml:"username"`
+	Password      string        `envconfig:"PASSWORD" yaml:"password"`
+	From          string        `envconfig:"FROM" yaml:"from"`
+	Templates     string        `envconfig:"TEMPLATES" yaml:"templates"`
+	DefaultLocale string        `envconfig:"DEFAULT_LOCALE" yaml:"defaultLocale"`
+	Timeout       time.Duration `envconfig:"TIMEOUT" yaml:"timeout"`
+}
+
+// Default returns the configuration used for every field that is set neither in the config file nor in the environment.
+func Default() Config {
+	return Config{
+		Nats: cloudeventprovider.NatsConfig{
+			Url:          "nats://127.0.0.1:4222",
+			TimeoutInSec: 10 * time.Second,
+		},
+		LogLevel:        "info",
+		ShutdownTimeout: 30 * time.Second,
+		Signer: SignerConfig{
+			Timeout:          10 * time.Second,
+			Retries:          2,
+			RetryBackoff:     200 * time.Millisecond,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+			MaxIdleConns:     10,
+		},
+		Storage: StorageConfig{
+			Type:           StorageTypeMemory,
+			TTL:            24 * time.Hour,
+			ExpiryInterval: time.Minute,
+			Retention:      30 * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			NotificationTopic:      "issuer.dummycontentsigner.events.notification",
+			OfferCreatedTopic:      "issuer.dummycontentsigner.events.offer.created",
+			OfferExpiredTopic:      "issuer.dummycontentsigner.events.offer.expired",
+			OfferCancelledTopic:    "issuer.dummycontentsigner.events.offer.cancelled",
+			CredentialIssuedTopic:  "issuer.dummycontentsigner.events.credential.issued",
+			CredentialFailedTopic:  "issuer.dummycontentsigner.events.credential.failed",
+			CredentialRevokedTopic: "issuer.dummycontentsigner.events.credential.revoked",
+		},
+		Http: HttpConfig{
+			Addr:      ":8080",
+			AdminAddr: ":8081",
+		},
+		Audit: AuditConfig{
+			QueryTopic: "issuer.dummycontentsigner.audit.query",
+		},
+		Idempotency: IdempotencyConfig{
+			Window: 10 * time.Minute,
+		},
+		Limits: LimitsConfig{
+			UsageTopic: "issuer.dummycontentsigner.limits.usage",
+		},
+		Approval: ApprovalConfig{
+			Topic: "issuer.dummycontentsigner.approval",
+		},
+		Offers: OffersConfig{
+			Topic: "issuer.dummycontentsigner.offers",
+		},
+		Bulk: BulkConfig{
+			Parallelism: 4,
+		},
+		Mail: MailConfig{
+			Port:          587,
+			Security:      "starttls",
+			DefaultLocale: "en",
+			Timeout:       30 * time.Second,
+		},
+	}
 }
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultFile = "config.yaml"
+
+// Load reads the configuration in three layers: the defaults, the YAML file named by CONFIG_FILE
+// (config.yaml in the working directory if unset and present) and finally the environment.
+func Load() (Config, error) {
+	conf := Default()
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = DefaultFile
+	}
+
+	if err := readFile(path, &conf); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return conf, err
+		}
+	}
+
+	// envconfig applies the default tag of the NATS settings when NATS_URL is unset, keep the configured url instead
+	natsUrl := conf.Nats.Url
+	if err := envconfig.Process("", &conf); err != nil {
+		return conf, fmt.Errorf("failed to load config from env: %w", err)
+	}
+	if _, ok := os.LookupEnv("NATS_URL"); !ok {
+		conf.Nats.Url = natsUrl
+	}
+
+	return conf, conf.Validate()
+}
+
+func readFile(path string, conf *Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(b, conf); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validFile = `
+signerUrl: http://signer.example.org/v1/credential
+signerKey: filekey
+nats:
+  url: nats://file:4222
+  timeoutinsec: 5s
+http:
+  addr: ":9090"
+`
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		check   func(t *testing.T, c Config)
+		wantErr []string
+	}{
+		{
+			name: "file only",
+			file: validFile,
+			check: func(t *testing.T, c Config) {
+				if c.SignerKey != "filekey" || c.Http.Addr != ":9090" || c.Nats.TimeoutInSec != 5*time.Second {
+					t.Errorf("file values not applied: %+v", c)
+				}
+				if c.Signer.Retries != 2 || c.Storage.TTL != 24*time.Hour {
+					t.Errorf("defaults not kept for keys missing in the file: %+v", c)
+				}
+			},
+		},
+		{
+			name: "file keeps its NATS url without NATS_URL",
+			file: validFile,
+			check: func(t *testing.T, c Config) {
+				if c.Nats.Url != "nats://file:4222" {
+					t.Errorf("Nats.Url = %q, want the url of the file", c.Nats.Url)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			file: validFile,
+			env:  map[string]string{"SIGNERKEY": "envkey", "NATS_URL": "nats://env:4222", "HTTP_ADDR": ":7070"},
+			check: func(t *testing.T, c Config) {
+				if c.SignerKey != "envkey" || c.Nats.Url != "nats://env:4222" || c.Http.Addr != ":7070" {
+					t.Errorf("env values not applied: %+v", c)
+				}
+				if c.Nats.TimeoutInSec != 5*time.Second {
+					t.Errorf("file value lost: %s", c.Nats.TimeoutInSec)
+				}
+			},
+		},
+		{
+			name: "env only",
+			env:  map[string]string{"SIGNERURL": "https://signer.example.org", "SIGNERKEY": "envkey"},
+			check: func(t *testing.T, c Config) {
+				if c.Nats.Url != Default().Nats.Url {
+					t.Errorf("Nats.Url = %q, want the default", c.Nats.Url)
+				}
+			},
+		},
+		{
+			name:    "missing signer",
+			env:     map[string]string{"SIGNERURL": ""},
+			wantErr: []string{"SIGNERURL (signerUrl): is required", "SIGNERKEY (signerKey): is required"},
+		},
+		{
+			name: "every invalid field at once",
+			file: validFile,
+			env: map[string]string{
+				"LOG_LEVEL":          "verbose",
+				"SIGNER_RETRIES":     "-1",
+				"SIGNER_TOKEN_URL":   "https://idp.example.org/token",
+				"HTTP_ADMIN_TOKENS":  "alice:secret",
+				"HTTP_ADMIN_ADDR":    ":9090",
+				"MAIL_HOST":          "smtp.example.org",
+				"MAIL_SECURITY":      "sometimes",
+				"IDEMPOTENCY_WINDOW": "-1s",
+			},
+			wantErr: []string{
+				"LOG_LEVEL (logLevel)",
+				"SIGNER_RETRIES (signer.retries)",
+				"SIGNER_CLIENT_ID (signer.clientId)",
+				"HTTP_ADMIN_ADDR (http.adminAddr): must differ from HTTP_ADDR",
+				"MAIL_FROM (mail.from)",
+				"MAIL_SECURITY (mail.security)",
+				"IDEMPOTENCY_WINDOW (idempotency.window)",
+			},
+		},
+		{
+			name:    "certificate without key",
+			file:    validFile + "signer:\n  certFile: /tls/cert.pem\n",
+			wantErr: []string{"SIGNER_CERT_FILE/SIGNER_KEY_FILE"},
+		},
+		{
+			name:    "unparsable file",
+			file:    "nats: [",
+			wantErr: []string{"failed to parse config file"},
+		},
+		{
+			name:    "unparsable env",
+			file:    validFile,
+			env:     map[string]string{"SIGNER_RETRIES": "two"},
+			wantErr: []string{"failed to load config from env"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			t.Setenv("CONFIG_FILE", "")
+			os.Unsetenv("CONFIG_FILE")
+			for _, k := range []string{"NATS_URL", "SIGNERURL", "SIGNERKEY", "HTTP_ADDR"} {
+				t.Setenv(k, "")
+				os.Unsetenv(k)
+			}
+			if tt.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, DefaultFile), []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			c, err := Load()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				tt.check(t, c)
+				return
+			}
+			if err == nil {
+				t.Fatal("Load() succeeded")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error does not name %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadExplicitFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	t.Setenv("CONFIG_FILE", filepath.Join(dir, "missing.yaml"))
+	if _, err := Load(); err == nil {
+		t.Error("Load() succeeded without the file named by CONFIG_FILE")
+	}
+
+	path := filepath.Join(dir, "issuer.yaml")
+	if err := os.WriteFile(path, []byte(validFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SignerKey != "filekey" {
+		t.Errorf("SignerKey = %q, want the key of CONFIG_FILE", c.SignerKey)
+	}
+}
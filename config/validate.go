@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validate reports every invalid field at once, naming the environment variable and the file key to fix.
+func (c Config) Validate() error {
+	var errs []error
+
+	fail := func(env string, key string, msg string, args ...any) {
+		if env == "" {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(msg, args...)))
+			return
+		}
+		errs = append(errs, fmt.Errorf("%s (%s): %s", env, key, fmt.Sprintf(msg, args...)))
+	}
+
+	if c.Nats.Url == "" {
+		fail("NATS_URL", "nats.url", "is required, e.g. nats://localhost:4222")
+	}
+	if c.Nats.TimeoutInSec < 0 {
+		fail("NATS_REQUEST_TIMEOUT", "nats.timeoutinsec", "must not be negative, got %s", c.Nats.TimeoutInSec)
+	}
+
+	if c.SignerUrl == "" {
+		fail("SIGNERURL", "signerUrl", "is required, e.g. http://signer:8080/v1/credential")
+	} else if !isHttpUrl(c.SignerUrl) {
+		fail("SIGNERURL", "signerUrl", "must be an absolute http(s) url, got %q", c.SignerUrl)
+	}
+	if c.SignerKey == "" && !c.tenantsHaveDefaultKeys() {
+		fail("SIGNERKEY", "signerKey", "is required unless every tenant has a key without configuration, it names the key the signer uses")
+	}
+
+	for _, v := range []struct {
+		env, key, value string
+	}{
+		{"ORIGIN", "origin", c.Origin},
+		{"CREDENTIAL_ISSUER", "credentialIssuer", c.Credential_Issuer},
+		{"CREDENTIAL_ENDPOINT", "credentialEndpoint", c.Credential_Endpoint},
+		{"SIGNER_TOKEN_URL", "signer.tokenUrl", c.Signer.TokenUrl},
+	} {
+		if v.value != "" && !isHttpUrl(v.value) {
+			fail(v.env, v.key, "must be an absolute http(s) url, got %q", v.value)
+		}
+	}
+	for _, s := range c.Authorization_Server {
+		if !isHttpUrl(s) {
+			fail("AUTHORIZATION_SERVER", "authorizationServer", "must be a list of absolute http(s) urls, got %q", s)
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("LOG_LEVEL", "logLevel", "must be one of debug, info, warn, error, got %q", c.LogLevel)
+	}
+	if c.ShutdownTimeout <= 0 {
+		fail("SHUTDOWN_TIMEOUT", "shutdownTimeout", "must be positive, got %s", c.ShutdownTimeout)
+	}
+
+	if c.Signer.Timeout <= 0 {
+		fail("SIGNER_TIMEOUT", "signer.timeout", "must be positive, got %s", c.Signer.Timeout)
+	}
+	if c.Signer.Retries < 0 {
+		fail("SIGNER_RETRIES", "signer.retries", "must not be negative, got %d", c.Signer.Retries)
+	}
+	if c.Signer.RetryBackoff < 0 {
+		fail("SIGNER_RETRY_BACKOFF", "signer.retryBackoff", "must not be negative, got %s", c.Signer.RetryBackoff)
+	}
+	if c.Signer.BreakerThreshold < 0 {
+		fail("SIGNER_BREAKER_THRESHOLD", "signer.breakerThreshold", "must not be negative (0 disables the breaker), got %d", c.Signer.BreakerThreshold)
+	}
+	if c.Signer.BreakerThreshold > 0 && c.Signer.BreakerCooldown <= 0 {
+		fail("SIGNER_BREAKER_COOLDOWN", "signer.breakerCooldown", "must be positive while the breaker is enabled, got %s", c.Signer.BreakerCooldown)
+	}
+	if (c.Signer.CertFile == "") != (c.Signer.KeyFile == "") {
+		fail("SIGNER_CERT_FILE/SIGNER_KEY_FILE", "signer.certFile/signer.keyFile", "must be set together")
+	}
+	if c.Signer.TokenUrl != "" && c.Signer.ClientId == "" {
+		fail("SIGNER_CLIENT_ID", "signer.clientId", "is required when SIGNER_TOKEN_URL is set")
+	}
+
+	for i, t := range c.Tenants {
+		key := fmt.Sprintf("tenants[%d]", i)
+		if t.CredentialIssuer != "" && !isHttpUrl(t.CredentialIssuer) {
+			fail("", key+".credentialIssuer", "must be an absolute http(s) url, got %q", t.CredentialIssuer)
+		}
+		if t.CredentialEndpoint != "" && !isHttpUrl(t.CredentialEndpoint) {
+			fail("", key+".credentialEndpoint", "must be an absolute http(s) url, got %q", t.CredentialEndpoint)
+		}
+		for _, s := range t.AuthorizationServers {
+			if !isHttpUrl(s) {
+				fail("", key+".authorizationServers", "must be a list of absolute http(s) urls, got %q", s)
+			}
+		}
+	}
+
+	if c.Storage.Type != StorageTypeMemory {
+		fail("STORAGE_TYPE", "storage.type", "must be %q, got %q", StorageTypeMemory, c.Storage.Type)
+	}
+	if c.Storage.TTL <= 0 {
+		fail("STORAGE_TTL", "storage.ttl", "must be positive, got %s", c.Storage.TTL)
+	}
+
+	if c.Storage.ExpiryInterval <= 0 {
+		fail("STORAGE_EXPIRY_INTERVAL", "storage.expiryInterval", "must be positive, got %s", c.Storage.ExpiryInterval)
+	}
+	if c.Storage.Retention < 0 {
+		fail("STORAGE_RETENTION", "storage.retention", "must not be negative, got %s", c.Storage.Retention)
+	}
+
+	if c.Http.Addr == "" {
+		fail("HTTP_ADDR", "http.addr", "is required, e.g. :8080")
+	}
+	if len(c.Http.AdminTokens) > 0 {
+		if c.Http.AdminAddr == "" {
+			fail("HTTP_ADMIN_ADDR", "http.adminAddr", "is required while admin tokens are configured, e.g. :8081")
+		} else if c.Http.AdminAddr == c.Http.Addr {
+			fail("HTTP_ADMIN_ADDR", "http.adminAddr", "must differ from HTTP_ADDR, got %q", c.Http.AdminAddr)
+		}
+	}
+	for name, token := range c.Http.AdminTokens {
+		if name == "" || token == "" {
+			fail("HTTP_ADMIN_TOKENS", "http.adminTokens", "must map caller names to non-empty tokens, e.g. alice:s3cret")
+			break
+		}
+	}
+
+	if c.Offers.ByReference && !isHttpUrl(c.Offers.BaseUrl) {
+		fail("OFFERS_BASE_URL", "offers.baseUrl", "must be an absolute http(s) url while offers are passed by reference, got %q", c.Offers.BaseUrl)
+	}
+
+	if c.Bulk.Dir != "" && c.Bulk.Parallelism <= 0 {
+		fail("BULK_PARALLELISM", "bulk.parallelism", "must be positive while bulk issuance is enabled, got %d", c.Bulk.Parallelism)
+	}
+
+	if c.Mail.Host != "" {
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			fail("MAIL_FROM", "mail.from", "must be an email address while mail delivery is enabled, got %q", c.Mail.From)
+		}
+		if c.Mail.Port <= 0 || c.Mail.Port > 65535 {
+			fail("MAIL_PORT", "mail.port", "must be a port number, got %d", c.Mail.Port)
+		}
+		if !slices.Contains([]string{"starttls", "opportunistic", "tls", "none"}, c.Mail.Security) {
+			fail("MAIL_SECURITY", "mail.security", "must be one of starttls, opportunistic, tls, none, got %q", c.Mail.Security)
+		}
+		if c.Mail.Timeout <= 0 {
+			fail("MAIL_TIMEOUT", "mail.timeout", "must be positive, got %s", c.Mail.Timeout)
+		}
+	}
+
+	c.Limits.Request.validate(fail, "LIMITS_REQUEST_", "limits.request.")
+	c.Limits.Issue.validate(fail, "LIMITS_ISSUE_", "limits.issue.")
+	if c.Limits.DailyQuota < 0 {
+		fail("LIMITS_DAILY_QUOTA", "limits.dailyQuota", "must not be negative (0 is unlimited), got %d", c.Limits.DailyQuota)
+	}
+	for i, t := range c.Tenants {
+		key := fmt.Sprintf("tenants[%d].limits.", i)
+		t.Limits.Request.validate(fail, "", key+"request.")
+		t.Limits.Issue.validate(fail, "", key+"issue.")
+		if t.Limits.DailyQuota < 0 {
+			fail("", key+"dailyQuota", "must not be negative (0 keeps the global quota), got %d", t.Limits.DailyQuota)
+		}
+	}
+
+	for i, cl := range c.Claims {
+		key := fmt.Sprintf("claims[%d]", i)
+		if cl.Configuration == "" {
+			fail("", key+".configuration", "is required")
+		}
+		switch cl.Type {
+		case "http":
+			if !isHttpUrl(cl.Url) {
+				fail("", key+".url", "must be an absolute http(s) url, got %q", cl.Url)
+			}
+		case "sql":
+			if cl.Driver == "" || cl.Dsn == "" || cl.Query == "" {
+				fail("", key, "driver, dsn and query are required for type sql")
+			}
+		case "file":
+			if cl.File == "" {
+				fail("", key+".file", "is required for type file")
+			}
+		default:
+			fail("", key+".type", "must be one of http, sql, file, got %q", cl.Type)
+		}
+		if cl.Timeout < 0 {
+			fail("", key+".timeout", "must not be negative, got %s", cl.Timeout)
+		}
+	}
+
+	for i, m := range c.Mappings {
+		if m.Configuration == "" {
+			fail("", fmt.Sprintf("mappings[%d].configuration", i), "is required")
+		}
+	}
+
+	if c.Idempotency.Window < 0 {
+		fail("IDEMPOTENCY_WINDOW", "idempotency.window", "must not be negative (0 disables de-duplication), got %s", c.Idempotency.Window)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (r RateConfig) validate(fail func(env string, key string, msg string, args ...any), env string, key string) {
+	name := func(s string) string {
+		if env == "" {
+			return ""
+		}
+		return env + s
+	}
+
+	if r.TenantRate < 0 {
+		fail(name("TENANT_RATE"), key+"tenantRate", "must not be negative (0 is unlimited), got %v", r.TenantRate)
+	}
+	if r.TenantBurst < 0 {
+		fail(name("TENANT_BURST"), key+"tenantBurst", "must not be negative, got %d", r.TenantBurst)
+	}
+	if r.ConfigurationRate < 0 {
+		fail(name("CONFIGURATION_RATE"), key+"configurationRate", "must not be negative (0 is unlimited), got %v", r.ConfigurationRate)
+	}
+	if r.ConfigurationBurst < 0 {
+		fail(name("CONFIGURATION_BURST"), key+"configurationBurst", "must not be negative, got %d", r.ConfigurationBurst)
+	}
+}
+
+func isHttpUrl(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && !strings.ContainsAny(s, " \t")
+}
+
+func (c Config) tenantsHaveDefaultKeys() bool {
+	if len(c.Tenants) == 0 {
+		return false
+	}
+
+	for _, t := range c.Tenants {
+		found := false
+		for _, k := range t.Keys {
+			found = found || k.Configuration == ""
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
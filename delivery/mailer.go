@@ -0,0 +1,125 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/offer"
+)
+
+// Delivery states of an offer sent by email.
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+const qrContentId = "offer-qr"
+
+var (
+	ErrQueueFull    = errors.New("mail queue is full")
+	ErrShuttingDown = errors.New("mail delivery stopped before the offer was sent")
+)
+
+// Request asks for an offer to be sent to the recipient.
+type Request struct {
+	Data
+	Recipient string
+}
+
+type job struct {
+	req  Request
+	done func(error)
+}
+
+// Mailer sends offers in the background, the outcome of each delivery is passed to its done function.
+// A nil Mailer sends nothing.
+type Mailer struct {
+	from      string
+	locale    string
+	templates *Templates
+	sender    Sender
+	queue     chan job
+}
+
+// New returns a Mailer if MAIL_HOST is set, nil otherwise.
+func New(conf config.Config, sender Sender) (*Mailer, error) {
+	if conf.Mail.Host == "" {
+		return nil, nil
+	}
+
+	templates, err := LoadTemplates(conf.Mail.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mailer{
+		from:      conf.Mail.From,
+		locale:    conf.Mail.DefaultLocale,
+		templates: templates,
+		sender:    sender,
+		queue:     make(chan job, 256),
+	}, nil
+}
+
+// Enqueue schedules the delivery, done is called once it succeeded or failed.
+func (m *Mailer) Enqueue(req Request, done func(error)) error {
+	if req.Locale == "" {
+		req.Locale = m.locale
+	}
+
+	select {
+	case m.queue <- job{req: req, done: done}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends the queued offers until ctx is done, offers still queued then are reported as failed.
+func (m *Mailer) Run(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case j := <-m.queue:
+					j.done(ErrShuttingDown)
+				default:
+					return
+				}
+			}
+		case j := <-m.queue:
+			j.done(m.deliver(ctx, j.req))
+		}
+	}
+}
+
+func (m *Mailer) deliver(ctx context.Context, req Request) error {
+	req.QRContentId = qrContentId
+	subject, body, err := m.templates.Render(req.Data)
+	if err != nil {
+		return err
+	}
+
+	msg := Message{
+		From:    m.from,
+		To:      req.Recipient,
+		Subject: subject,
+		Html:    body,
+	}
+	// offers too large for a QR code are sent with the link only
+	if image, _, err := offer.QR(req.Offer, offer.FormatPng, offer.DefaultSize); err == nil {
+		msg.Image, msg.ImageId, msg.ImageFormat = image, qrContentId, offer.FormatPng
+	} else {
+		logging.Request(req.TenantId, req.RequestId).Warn("offer is sent without QR code", "error", err)
+	}
+
+	return m.sender.Send(ctx, msg)
+}
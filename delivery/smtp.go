@@ -0,0 +1,118 @@
+package delivery
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Security modes of the SMTP connection.
+const (
+	SecurityStartTls      = "starttls"
+	SecurityOpportunistic = "opportunistic"
+	SecurityTls           = "tls"
+	SecurityNone          = "none"
+)
+
+var ErrStartTlsUnavailable = errors.New("smtp server does not offer STARTTLS")
+
+// Sender transports a message.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SmtpSender sends messages to the configured SMTP server. With starttls the connection must be
+// upgraded, servers not offering STARTTLS are refused. With opportunistic it is upgraded if the server
+// offers it and the message is sent in clear text otherwise; credentials are only sent over TLS.
+type SmtpSender struct {
+	conf config.MailConfig
+}
+
+func NewSmtpSender(conf config.MailConfig) *SmtpSender {
+	return &SmtpSender{conf: conf}
+}
+
+func (s *SmtpSender) Send(ctx context.Context, m Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return err
+	}
+	data, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.conf.Host, strconv.Itoa(s.conf.Port))
+	deadline := time.Now().Add(s.conf.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	if s.conf.Security == SecurityTls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.conf.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.conf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.conf.Security == SecurityStartTls || s.conf.Security == SecurityOpportunistic {
+		ok, _ := c.Extension("STARTTLS")
+		if ok {
+			if err := c.StartTLS(&tls.Config{ServerName: s.conf.Host}); err != nil {
+				return err
+			}
+		} else if s.conf.Security == SecurityStartTls {
+			return ErrStartTlsUnavailable
+		}
+	}
+	if s.conf.Username != "" {
+		// PlainAuth refuses unencrypted connections except to localhost
+		if err := c.Auth(smtp.PlainAuth("", s.conf.Username, s.conf.Password, s.conf.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,147 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// smtpStandIn answers one SMTP session, offering STARTTLS if asked to but never completing it.
+type smtpStandIn struct {
+	addr     *net.TCPAddr
+	startTls bool
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func newSmtpStandIn(t *testing.T, startTls bool) *smtpStandIn {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &smtpStandIn{addr: l.Addr().(*net.TCPAddr), startTls: startTls}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(textproto.NewConn(conn))
+	}()
+	return s
+}
+
+func (s *smtpStandIn) serve(c *textproto.Conn) {
+	_ = c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		s.mu.Lock()
+		s.commands = append(s.commands, verb)
+		s.mu.Unlock()
+
+		switch verb {
+		case "EHLO":
+			if s.startTls {
+				_ = c.PrintfLine("250-localhost")
+				_ = c.PrintfLine("250 STARTTLS")
+			} else {
+				_ = c.PrintfLine("250 localhost")
+			}
+		case "STARTTLS":
+			_ = c.PrintfLine("454 TLS not available")
+		case "MAIL", "RCPT":
+			_ = c.PrintfLine("250 OK")
+		case "DATA":
+			_ = c.PrintfLine("354 go ahead")
+			b, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(b)
+			s.mu.Unlock()
+			_ = c.PrintfLine("250 queued")
+		case "QUIT":
+			_ = c.PrintfLine("221 bye")
+			return
+		default:
+			_ = c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpStandIn) session() ([]string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.commands), s.data
+}
+
+func TestSmtpSender(t *testing.T) {
+	tests := []struct {
+		name          string
+		security      string
+		offerStartTls bool
+		wantErr       bool
+		wantErrIs     error
+		wantStartTls  bool
+		wantSent      bool
+	}{
+		{name: "none", security: SecurityNone, wantSent: true},
+		{name: "starttls not offered", security: SecurityStartTls, wantErr: true, wantErrIs: ErrStartTlsUnavailable},
+		{name: "starttls failing", security: SecurityStartTls, offerStartTls: true, wantErr: true, wantStartTls: true},
+		{name: "opportunistic not offered", security: SecurityOpportunistic, wantSent: true},
+		{name: "opportunistic failing", security: SecurityOpportunistic, offerStartTls: true, wantErr: true, wantStartTls: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn := newSmtpStandIn(t, tt.offerStartTls)
+			sender := NewSmtpSender(config.MailConfig{
+				Host:     "127.0.0.1",
+				Port:     standIn.addr.Port,
+				Security: tt.security,
+				Timeout:  5 * time.Second,
+			})
+
+			err := sender.Send(context.Background(), Message{
+				From:    "issuer@example.org",
+				To:      "holder@example.org",
+				Subject: "Your credential",
+				Html:    "<p>offer</p>",
+			})
+			if (err != nil) != tt.wantErr || (tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs)) {
+				t.Fatalf("Send() error = %v, want error %v %v", err, tt.wantErr, tt.wantErrIs)
+			}
+
+			// the stand-in records each command before answering it
+			commands, data := standIn.session()
+			if got := slices.Contains(commands, "STARTTLS"); got != tt.wantStartTls {
+				t.Errorf("STARTTLS sent = %v, want %v (%v)", got, tt.wantStartTls, commands)
+			}
+			if got := slices.Contains(commands, "MAIL"); got != tt.wantSent {
+				t.Errorf("message sent = %v, want %v (%v)", got, tt.wantSent, commands)
+			}
+			if tt.wantSent && !strings.Contains(data, "To: holder@example.org") {
+				t.Errorf("message data = %q", data)
+			}
+		})
+	}
+}
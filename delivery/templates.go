@@ -0,0 +1,135 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultTenant names the template directory used for tenants without templates of their own.
+const DefaultTenant = "default"
+
+const builtin = `{{define "subject"}}Your credential offer{{end}}<!DOCTYPE html>
+<html>
+<body>
+<p>A credential is waiting for you. Open the offer with your wallet:</p>
+<p><a href="{{.Offer}}">Open credential offer</a></p>
+<p>Or scan this code with your wallet:</p>
+<p><img src="cid:{{.QRContentId}}" alt="QR code of the credential offer"></p>
+</body>
+</html>
+`
+
+// Data is passed to the templates.
+type Data struct {
+	TenantId        string
+	RequestId       string
+	ConfigurationId string
+	Locale          string
+	// Offer is the credential offer link, by value or by reference
+	Offer       string
+	QRContentId string
+}
+
+// Templates holds the mail templates per tenant and locale. A template renders the html body and
+// defines "subject".
+type Templates struct {
+	byKey map[string]*template.Template
+}
+
+// LoadTemplates reads {dir}/{tenant}/{locale}.html, where tenant is a tenant id or default and locale a
+// language tag such as de-DE or de. An empty dir uses the built-in template only.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{byKey: make(map[string]*template.Template)}
+
+	fallback, err := template.New("builtin").Parse(builtin)
+	if err != nil {
+		return nil, err
+	}
+	t.byKey[""] = fallback
+
+	if dir == "" {
+		return t, nil
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		tenant, file, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok || strings.Contains(file, "/") {
+			return fmt.Errorf("mail template %s must be stored as {tenant}/{locale}.html", path)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(rel).Parse(string(b))
+		if err != nil {
+			return fmt.Errorf("mail template %s: %w", path, err)
+		}
+		if tmpl.Lookup("subject") == nil {
+			return fmt.Errorf("mail template %s: subject is not defined", path)
+		}
+
+		t.byKey[key(tenant, strings.TrimSuffix(file, ".html"))] = tmpl
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("mail templates %s: %w", dir, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func key(tenant string, locale string) string {
+	return tenant + "/" + strings.ToLower(locale)
+}
+
+// lookup returns the template of the tenant for the locale, falling back from de-DE to de, from the
+// tenant to the default templates and finally to the built-in template.
+func (t *Templates) lookup(tenant string, locale string) *template.Template {
+	language, _, _ := strings.Cut(locale, "-")
+	for _, k := range []string{
+		key(tenant, locale), key(tenant, language),
+		key(DefaultTenant, locale), key(DefaultTenant, language),
+	} {
+		if tmpl, ok := t.byKey[k]; ok {
+			return tmpl
+		}
+	}
+	return t.byKey[""]
+}
+
+// view marks the offer as trusted url, html/template would replace the openid-credential-offer scheme.
+type view struct {
+	Data
+	Offer template.URL
+}
+
+// Render returns the subject and html body for the data.
+func (t *Templates) Render(data Data) (string, string, error) {
+	tmpl := t.lookup(data.TenantId, data.Locale)
+	v := view{Data: data, Offer: template.URL(data.Offer)}
+
+	var subject, body strings.Builder
+	if err := tmpl.ExecuteTemplate(&subject, "subject", v); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.Execute(&body, v); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(subject.String()), body.String(), nil
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Event types emitted by the module, used as CloudEvent type.
+const (
+	TypeOfferCreated      = "offer.created"
+	TypeOfferExpired      = "offer.expired"
+	TypeOfferCancelled    = "offer.cancelled"
+	TypeCredentialIssued  = "credential.issued"
+	TypeCredentialFailed  = "credential.failed"
+	TypeCredentialRevoked = "credential.revoked"
+
+	// notifications sent by wallets
+	TypeCredentialAccepted = "credential.accepted"
+	TypeCredentialFailure  = "credential.failure"
+	TypeCredentialDeleted  = "credential.deleted"
+)
+
+// SchemaVersion is increased on incompatible changes of Event. Fields are only added within a version.
+const SchemaVersion = "1"
+
+// Event is the data of every CloudEvent emitted by the module. It never contains codes, nonces or claims.
+type Event struct {
+	Type            string    `json:"type"`
+	SchemaVersion   string    `json:"schema_version"`
+	TenantId        string    `json:"tenant_id"`
+	RequestId       string    `json:"request_id,omitempty"`
+	ConfigurationId string    `json:"configuration_id,omitempty"`
+	NotificationId  string    `json:"notification_id,omitempty"`
+	Format          string    `json:"format,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// Publisher emits events as CloudEvents on NATS. Clients are created on first use per topic.
+// A nil Publisher drops all events.
+type Publisher struct {
+	conf    config.Config
+	mu      sync.Mutex
+	clients map[string]*cloudeventprovider.CloudEventProviderClient
+}
+
+func New(conf config.Config) *Publisher {
+	return &Publisher{
+		conf:    conf,
+		clients: make(map[string]*cloudeventprovider.CloudEventProviderClient),
+	}
+}
+
+// topic returns the subject of an event type, an empty subject disables the event.
+func (p *Publisher) topic(eventType string) string {
+	switch eventType {
+	case TypeOfferCreated:
+		return p.conf.Events.OfferCreatedTopic
+	case TypeOfferExpired:
+		return p.conf.Events.OfferExpiredTopic
+	case TypeOfferCancelled:
+		return p.conf.Events.OfferCancelledTopic
+	case TypeCredentialIssued:
+		return p.conf.Events.CredentialIssuedTopic
+	case TypeCredentialFailed:
+		return p.conf.Events.CredentialFailedTopic
+	case TypeCredentialRevoked:
+		return p.conf.Events.CredentialRevokedTopic
+	default:
+		return p.conf.Events.NotificationTopic
+	}
+}
+
+func (p *Publisher) client(topic string) (*cloudeventprovider.CloudEventProviderClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.clients[topic]; ok {
+		return c, nil
+	}
+
+	c, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: p.conf.Nats},
+		cloudeventprovider.ConnectionTypePub,
+		topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.clients[topic] = c
+	return c, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, e Event) error {
+	if p == nil {
+		return nil
+	}
+
+	topic := p.topic(e.Type)
+	if topic == "" {
+		return nil
+	}
+
+	e.SchemaVersion = SchemaVersion
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	event, err := cloudeventprovider.NewEvent("test-issuer", e.Type, data)
+	if err != nil {
+		return err
+	}
+
+	c, err := p.client(topic)
+	if err != nil {
+		return err
+	}
+
+	return c.PubCtx(ctx, event)
+}
+
+func (p *Publisher) Close() error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for topic, c := range p.clients {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(p.clients, topic)
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+// Package fake provides stand-ins for the services around the module, so that it can be exercised
+// offline in unit and integration tests: an embedded NATS server, a signer signing with a test key
+// and an offering responder in place of the issuer service. Environment wires them together and runs
+// the handlers of the module in-process.
+package fake
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/signer"
+	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+)
+
+// shutdownTimeout bounds Close, requests still in flight afterwards are cancelled.
+const shutdownTimeout = 5 * time.Second
+
+// Environment is a running set of fakes. Config points NATS and SIGNERURL at them and can be changed
+// before StartModule.
+type Environment struct {
+	Nats     *Nats
+	Signer   *Signer
+	Offering *Offering
+	Config   config.Config
+
+	ctx          context.Context
+	cancel       context.CancelFunc
+	signerServer *httptest.Server
+	wg           sync.WaitGroup
+
+	mu      sync.Mutex
+	drains  []func(context.Context) error
+	clients []*cloudeventprovider.CloudEventProviderClient
+}
+
+// Start runs the fakes until Close, ctx bounds the startup.
+func Start(ctx context.Context) (*Environment, error) {
+	n, err := StartNats()
+	if err != nil {
+		return nil, err
+	}
+
+	fakeSigner, err := NewSigner()
+	if err != nil {
+		n.Close()
+		return nil, err
+	}
+
+	conf := config.Default()
+	conf.Nats.Url = n.Url()
+	conf.SignerKey = "test"
+
+	e := &Environment{
+		Nats:         n,
+		Signer:       fakeSigner,
+		Offering:     NewOffering(metadata.Registration.Issuer.CredentialIssuer),
+		signerServer: httptest.NewServer(fakeSigner),
+	}
+	conf.SignerUrl = e.signerServer.URL
+	e.Config = conf
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if err := e.Offering.Serve(e.ctx, conf.Nats); err != nil && e.ctx.Err() == nil {
+			slog.Error("offering responder stopped", "error", err)
+		}
+	}()
+
+	if err := n.WaitForSubjects(ctx, issumsg.TopicOffering); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// StartModule runs the request, issue, notification, revocation and offer management handlers of
+// the module with e.Config and returns once they are subscribed. svc needs a Storage, the other
+// services are optional as in the module.
+func (e *Environment) StartModule(ctx context.Context, svc issuance.Services) error {
+	if svc.Storage == nil {
+		return errors.New("services need a storage")
+	}
+	conf := e.Config
+
+	signerClient, err := signer.New(conf)
+	if err != nil {
+		return err
+	}
+
+	offering, err := issuance.NewOfferingClient(conf)
+	if err != nil {
+		return err
+	}
+
+	moduleCtx, drain := issuance.Tracked(e.ctx)
+	e.mu.Lock()
+	e.drains = append(e.drains, drain)
+	e.clients = append(e.clients, offering)
+	e.mu.Unlock()
+
+	for _, run := range []func(){
+		func() { issuance.CredentialRequest(moduleCtx, conf, svc) },
+		func() { issuance.CredentialReply(moduleCtx, conf, svc, signerClient) },
+		func() { issuance.CredentialNotification(moduleCtx, conf, svc) },
+		func() { issuance.CredentialRevocation(moduleCtx, conf, svc) },
+		func() { issuance.Offers(moduleCtx, conf, svc, offering) },
+	} {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			run()
+		}()
+	}
+
+	var subjects []string
+	for id := range metadata.Registration.Issuer.CredentialConfigurationsSupported {
+		subject, err := metadata.Subject(conf, id)
+		if err != nil {
+			return err
+		}
+		for _, suffix := range []string{".request", ".issue", ".notification", ".revoke"} {
+			subjects = append(subjects, subject+suffix)
+		}
+	}
+	if conf.Offers.Topic != "" {
+		subjects = append(subjects, conf.Offers.Topic)
+	}
+	return e.Nats.WaitForSubjects(ctx, subjects...)
+}
+
+// Close drains and stops the module handlers, then the fakes.
+func (e *Environment) Close() error {
+	e.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	e.mu.Lock()
+	drains, clients := e.drains, e.clients
+	e.mu.Unlock()
+
+	var errs []error
+	for _, drain := range drains {
+		errs = append(errs, drain(ctx))
+	}
+	e.wg.Wait()
+	for _, c := range clients {
+		errs = append(errs, c.Close())
+	}
+
+	e.signerServer.Close()
+	e.Nats.Close()
+	return errors.Join(errs...)
+}
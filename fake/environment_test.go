@@ -0,0 +1,141 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/bulk"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/wallet"
+	"github.com/google/uuid"
+)
+
+func startEnvironment(t *testing.T, ctx context.Context) *Environment {
+	t.Helper()
+
+	env, err := Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := env.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	if err := env.StartModule(ctx, issuance.Services{Storage: issuance.NewDummyStorage(env.Config.Storage.TTL)}); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// offer asks .request of the module for an offer, like the issuer service does.
+func offer(t *testing.T, ctx context.Context, env *Environment, configurationId string) string {
+	t.Helper()
+
+	requester := bulk.NewNatsRequester(env.Config)
+	defer requester.Close()
+
+	reply, err := requester.Request(ctx, messaging.IssuanceRequest{
+		Request:    common.Request{TenantId: metadata.DefaultTenant, RequestId: uuid.NewString()},
+		Payload:    map[string]interface{}{"given_name": "Jane", "family_name": "Doe"},
+		Identifier: configurationId,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Error != nil {
+		t.Fatalf("offer refused: %s", reply.Error.Msg)
+	}
+	return reply.Offer.CredentialOffer
+}
+
+func redeem(t *testing.T, ctx context.Context, conf wallet.Config, offer string) (*wallet.Credential, error) {
+	t.Helper()
+
+	w, err := wallet.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.Redeem(ctx, offer)
+}
+
+func TestRedeem(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	env := startEnvironment(t, ctx)
+
+	tests := []struct {
+		name            string
+		configurationId string
+		encrypt         bool
+	}{
+		{name: "ldp_vc", configurationId: metadata.Credential_Identifier},
+		{name: "ldp_vc encrypted", configurationId: metadata.Credential_Identifier, encrypt: true},
+		{name: "sd-jwt", configurationId: metadata.Credential_Identifier2},
+		{name: "sd-jwt encrypted", configurationId: metadata.Credential_Identifier2, encrypt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := redeem(t, ctx, wallet.Config{
+				Nats:     env.Config.Nats,
+				TenantId: metadata.DefaultTenant,
+				Keys:     env.Signer.Keys(),
+				Encrypt:  tt.encrypt,
+			}, offer(t, ctx, env, tt.configurationId))
+			if err != nil {
+				t.Fatalf("Redeem() error = %v", err)
+			}
+			if c.ConfigurationId != tt.configurationId || c.NotificationId == "" {
+				t.Errorf("credential = %+v", c)
+			}
+			if c.Claims["given_name"] != "Jane" {
+				t.Errorf("claims = %v, want given_name Jane", c.Claims)
+			}
+		})
+	}
+}
+
+func TestRedeemTwice(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	env := startEnvironment(t, ctx)
+
+	conf := wallet.Config{Nats: env.Config.Nats, TenantId: metadata.DefaultTenant, Keys: env.Signer.Keys()}
+	o := offer(t, ctx, env, metadata.Credential_Identifier)
+	if _, err := redeem(t, ctx, conf, o); err != nil {
+		t.Fatal(err)
+	}
+
+	var replyErr *wallet.ReplyError
+	if _, err := redeem(t, ctx, conf, o); !errors.As(err, &replyErr) || replyErr.Reply.Id != "offer-redeemed" {
+		t.Errorf("second Redeem() error = %v, want offer-redeemed", err)
+	}
+}
+
+func TestRedeemUntrustedIssuer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	env := startEnvironment(t, ctx)
+
+	other, err := NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := redeem(t, ctx, wallet.Config{
+		Nats:     env.Config.Nats,
+		TenantId: metadata.DefaultTenant,
+		Keys:     other.Keys(),
+	}, offer(t, ctx, env, metadata.Credential_Identifier2))
+	if !errors.Is(err, wallet.ErrUnknownKey) || c == nil {
+		t.Errorf("Redeem() = %v, %v, want the credential with %v", c, err, wallet.ErrUnknownKey)
+	}
+}
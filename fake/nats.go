@@ -0,0 +1,70 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// Nats is an embedded NATS server listening on a free local port.
+type Nats struct {
+	server *server.Server
+}
+
+// StartNats starts the server and waits until it accepts connections.
+func StartNats() (*Nats, error) {
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		s.Shutdown()
+		return nil, errors.New("embedded NATS server did not start")
+	}
+	return &Nats{server: s}, nil
+}
+
+func (n *Nats) Url() string {
+	return n.server.ClientURL()
+}
+
+// WaitForSubjects returns once every subject has a subscriber. Requests sent earlier would find
+// no responder, as the handlers of the module subscribe in the background.
+func (n *Nats) WaitForSubjects(ctx context.Context, subjects ...string) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		missing := ""
+		for _, s := range subjects {
+			if !n.server.GlobalAccount().SubscriptionInterest(s) {
+				missing = s
+				break
+			}
+		}
+		if missing == "" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no subscriber for %s: %w", missing, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Nats) Close() {
+	n.server.Shutdown()
+	n.server.WaitForShutdown()
+}
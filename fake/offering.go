@@ -0,0 +1,113 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"sync"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+	"github.com/google/uuid"
+)
+
+// Offering answers issumsg.TopicOffering like the issuer service: every request gets a new
+// pre-authorized code and an offer for the requested configurations.
+type Offering struct {
+	CredentialIssuer string
+
+	mu       sync.Mutex
+	requests []issumsg.OfferingURLReq
+}
+
+// NewOffering returns an Offering naming credentialIssuer in its offers.
+func NewOffering(credentialIssuer string) *Offering {
+	return &Offering{CredentialIssuer: credentialIssuer}
+}
+
+// Serve answers offering requests until ctx is done.
+func (o *Offering) Serve(ctx context.Context, conf cloudeventprovider.NatsConfig) error {
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf},
+		cloudeventprovider.ConnectionTypeRep,
+		issumsg.TopicOffering,
+	)
+	if err != nil {
+		return err
+	}
+
+	// like serve in the module: ReplyCtx ends with the client, a client whose ReplyCtx has returned
+	// blocks in Close
+	done := make(chan error, 1)
+	go func() {
+		done <- client.ReplyCtx(context.Background(), o.reply)
+	}()
+
+	select {
+	case <-ctx.Done():
+		err := client.Close()
+		<-done
+		return err
+	case err := <-done:
+		return err
+	}
+}
+
+func (o *Offering) reply(ctx context.Context, ev event.Event) (*event.Event, error) {
+	var req issumsg.OfferingURLReq
+	if err := json.Unmarshal(ev.Data(), &req); err != nil {
+		slog.Error("invalid offering request", "event_id", ev.ID(), "error", err)
+		return nil, err
+	}
+
+	b, err := json.Marshal(o.offer(req))
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cloudeventprovider.NewEvent("fake-issuer-service", issumsg.EventTypeOffering, b)
+	return &reply, err
+}
+
+func (o *Offering) offer(req issumsg.OfferingURLReq) issumsg.OfferingURLResp {
+	o.mu.Lock()
+	o.requests = append(o.requests, req)
+	o.mu.Unlock()
+
+	rep := issumsg.OfferingURLResp{Reply: common.Reply{
+		TenantId:  req.TenantId,
+		RequestId: req.RequestId,
+		GroupId:   req.GroupId,
+	}}
+	if req.Params.GrantType != "urn:ietf:params:oauth:grant-type:pre-authorized_code" || len(req.Params.CredentialConfigurations) == 0 {
+		rep.Error = &common.Error{
+			Id:     "invalid-offering-request",
+			Status: 400,
+			Msg:    "a pre-authorized code grant for at least one credential configuration is required",
+		}
+		return rep
+	}
+
+	params := credential.CredentialOfferParameters{CredentialIssuer: o.CredentialIssuer}
+	for _, c := range req.Params.CredentialConfigurations {
+		params.Credentials = append(params.Credentials, c.Id)
+	}
+	rep.Code = uuid.NewString()
+	params.Grants.PreAuthorizedCode = &credential.PreAuthorizedCode{PreAuthorizationCode: rep.Code}
+
+	b, _ := json.Marshal(params)
+	rep.CredentialOffer = credential.CredentialOffer{
+		CredentialOffer: "openid-credential-offer://?" + url.Values{"credential_offer": {string(b)}}.Encode(),
+	}
+	return rep
+}
+
+// Requests returns the offering requests answered so far.
+func (o *Offering) Requests() []issumsg.OfferingURLReq {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]issumsg.OfferingURLReq(nil), o.requests...)
+}
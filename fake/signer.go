@@ -0,0 +1,234 @@
+package fake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/wallet"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+// signerFields are the fields signCredential adds for the signer, they are not part of the credential.
+var signerFields = []string{"namespace", "group", "key", "algorithm", "status", "nonce", "format"}
+
+// Signer stands in for the signer behind SIGNERURL. It signs with one P-256 test key published as
+// did:jwk: ldp_vc credentials get an ecdsa-jcs-2019 DataIntegrityProof, every other format becomes an
+// SD-JWT with each claim of the credential subject selectively disclosable.
+type Signer struct {
+	key jwk.Key
+	kid string
+
+	mu       sync.Mutex
+	status   int
+	requests []map[string]any
+}
+
+// NewSigner returns a Signer with a fresh test key.
+func NewSigner() (*Signer, error) {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, err
+	}
+	did, err := wallet.DidJwk(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, kid: did + "#0"}, nil
+}
+
+// Kid is the verification method of the signatures, a did:jwk.
+func (s *Signer) Kid() string {
+	return s.kid
+}
+
+// Keys trusts the key of the signer in the wallet simulator.
+func (s *Signer) Keys() wallet.KeyResolver {
+	return wallet.TrustDids(s.kid)
+}
+
+// Fail answers every following request with status instead of signing, 0 signs again.
+func (s *Signer) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Requests returns the bodies received so far.
+func (s *Signer) Requests() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.requests...)
+}
+
+func (s *Signer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&body); err != nil || body == nil {
+		http.Error(w, "credential must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, body)
+	status := s.status
+	s.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if alg, _ := body["algorithm"].(string); alg != "" && alg != jwa.ES256.String() {
+		http.Error(w, fmt.Sprintf("algorithm %s is not supported by the test key", alg), http.StatusBadRequest)
+		return
+	}
+
+	format, _ := body["format"].(string)
+	credential := make(map[string]any, len(body))
+	for k, v := range body {
+		credential[k] = v
+	}
+	for _, f := range signerFields {
+		delete(credential, f)
+	}
+
+	var signed any
+	var err error
+	if format == "ldp_vc" {
+		signed, err = s.signLdp(credential)
+	} else {
+		signed, err = s.signSdJwt(credential, format)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(signed)
+}
+
+func (s *Signer) signLdp(document map[string]any) (map[string]any, error) {
+	proof := map[string]any{
+		"type":               "DataIntegrityProof",
+		"cryptosuite":        wallet.CryptosuiteEcdsaJcs,
+		"created":            time.Now().UTC().Format(time.RFC3339),
+		"verificationMethod": s.kid,
+		"proofPurpose":       "assertionMethod",
+	}
+
+	data, err := wallet.DataIntegrityHash(document, proof)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+
+	var raw ecdsa.PrivateKey
+	if err := s.key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	r, sig, err := ecdsa.Sign(rand.Reader, &raw, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, 64)
+	r.FillBytes(value[:32])
+	sig.FillBytes(value[32:])
+
+	proof["proofValue"] = wallet.MultibaseEncode(value)
+	document["proof"] = proof
+	return document, nil
+}
+
+func (s *Signer) signSdJwt(credential map[string]any, format string) (string, error) {
+	payload := map[string]any{
+		"iat":     time.Now().Unix(),
+		"_sd_alg": "sha-256",
+	}
+	if iss, ok := credential["issuer"]; ok {
+		payload["iss"] = iss
+	}
+	if vct, ok := credential["vct"]; ok {
+		payload["vct"] = vct
+	} else if types, _ := credential["type"].([]any); len(types) > 0 {
+		payload["vct"] = types[len(types)-1]
+	}
+	// the holder is bound by its key, other DIDs are kept as claim
+	if holder, _ := credential["holder"].(string); holder != "" {
+		if key, err := wallet.ResolveDidJwk(holder); err == nil {
+			payload["cnf"] = map[string]any{"jwk": key}
+		} else {
+			payload["holder"] = holder
+		}
+	}
+
+	subject, _ := credential["credentialSubject"].(map[string]any)
+	var disclosures []string
+	digests := []any{}
+	for name, value := range subject {
+		d, digest, err := disclosure(name, value)
+		if err != nil {
+			return "", err
+		}
+		disclosures = append(disclosures, d)
+		digests = append(digests, digest)
+	}
+	payload["_sd"] = digests
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	typ := "vc+sd-jwt"
+	if format == "dc+sd-jwt" {
+		typ = format
+	}
+	headers := jws.NewHeaders()
+	for k, v := range map[string]any{jws.TypeKey: typ, jws.KeyIDKey: s.kid} {
+		if err := headers.Set(k, v); err != nil {
+			return "", err
+		}
+	}
+	signed, err := jws.Sign(b, jws.WithKey(jwa.ES256, s.key, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		return "", err
+	}
+
+	return string(signed) + "~" + strings.Join(append(disclosures, ""), "~"), nil
+}
+
+// disclosure returns the encoded disclosure of a claim and its digest.
+func disclosure(name string, value any) (string, string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", "", err
+	}
+
+	b, err := json.Marshal([]any{base64.RawURLEncoding.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(b)
+	digest := sha256.Sum256([]byte(encoded))
+	return encoded, base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
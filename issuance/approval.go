@@ -0,0 +1,247 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/server"
+)
+
+// Approval decisions.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+var ErrNotPending = errors.New("credential is not pending approval")
+
+// ApprovalRequest decides on the credential prepared for the offer request with the tenant and
+// request id of the envelope.
+type ApprovalRequest struct {
+	common.Request
+	Decision string `json:"decision"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type ApprovalReply struct {
+	common.Reply
+	Status string `json:"status,omitempty"`
+}
+
+// requiresApproval reports whether credentials of the configuration must be approved for the tenant.
+func requiresApproval(conf config.Config, tenant config.TenantConfig, configurationId string) bool {
+	return slices.Contains(conf.Approval.Configurations, configurationId) || slices.Contains(tenant.Approvals, configurationId)
+}
+
+// decide records the decision on a pending credential. Approved credentials can be picked up,
+// rejected ones are refused by .issue.
+func decide(svc Services, req ApprovalRequest) (*CredentialRecord, error) {
+	if req.Decision != DecisionApprove && req.Decision != DecisionReject {
+		return nil, fmt.Errorf("decision must be %s or %s, got %q", DecisionApprove, DecisionReject, req.Decision)
+	}
+	if req.Approver == "" {
+		return nil, errors.New("approver is required")
+	}
+
+	record, err := svc.Storage.GetCredentialByRequest(req.TenantId, req.RequestId)
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != StatusPendingApproval {
+		return record, fmt.Errorf("%w, it is %s", ErrNotPending, record.Status)
+	}
+
+	record.Status = StatusPrepared
+	step := audit.StepCredentialApproved
+	if req.Decision == DecisionReject {
+		record.Status = StatusRejected
+		step = audit.StepCredentialRejected
+	}
+	record.Approver = req.Approver
+	record.DecisionReason = req.Reason
+	record.Decided = time.Now()
+
+	// a cancellation or a second decision in the meantime wins
+	if err := svc.Storage.TransitionCredential(record, StatusPendingApproval); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, fmt.Errorf("%w: %w", ErrNotPending, err)
+		}
+		return nil, err
+	}
+
+	logger := logging.Request(record.TenantId, record.RequestId).With(logging.KeyConfigurationId, record.ConfigurationId)
+	logger.Info("approval decided", "decision", req.Decision, "approver", req.Approver)
+	auditStep(svc.Trail, logger, audit.Entry{
+		Step:            step,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		Source:          req.Approver,
+		Detail:          req.Reason,
+	})
+
+	return record, nil
+}
+
+func approvalError(err error) *common.Error {
+	if errors.Is(err, ErrNotFound) {
+		return &common.Error{
+			Id:     "credential-not-found",
+			Status: 404,
+			Msg:    err.Error(),
+		}
+	}
+
+	if errors.Is(err, ErrNotPending) {
+		return &common.Error{
+			Id:     "not-pending-approval",
+			Status: 409,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "invalid-approval-request",
+		Status: 400,
+		Msg:    err.Error(),
+	}
+}
+
+// Approvals answers approval decisions on APPROVAL_TOPIC.
+func Approvals(ctx context.Context, conf config.Config, svc Services) {
+	if conf.Approval.Topic == "" {
+		return
+	}
+
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+		cloudeventprovider.ConnectionTypeRep,
+		conf.Approval.Topic,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("serving approvals", "subject", conf.Approval.Topic)
+	serve(ctx, client, conf.Nats.TimeoutInSec, approvalHandler(svc))
+}
+
+func approvalHandler(svc Services) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req ApprovalRequest
+		if err := json.Unmarshal(event.DataEncoded, &req); err != nil {
+			slog.Error("invalid approval request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		reply := ApprovalReply{Reply: common.Reply{
+			TenantId:  req.TenantId,
+			RequestId: req.RequestId,
+			GroupId:   req.GroupId,
+		}}
+
+		record, err := decide(svc, req)
+		if record != nil {
+			reply.Status = record.Status
+		}
+		if err != nil {
+			logging.Request(req.TenantId, req.RequestId).Warn("approval failed", "error", err)
+			reply.Error = approvalError(err)
+		}
+
+		return replyEvent(reply)
+	}
+}
+
+// PendingApproval is a credential waiting for a decision as listed over HTTP.
+type PendingApproval struct {
+	TenantId        string    `json:"tenant_id"`
+	RequestId       string    `json:"request_id"`
+	ConfigurationId string    `json:"configuration_id"`
+	Created         time.Time `json:"created"`
+}
+
+// ApprovalHandler serves GET /approvals?tenant_id= listing the pending credentials of a tenant and
+// POST /approvals/{tenant}/{request}/{decision} with {"reason"}. It expects to be served behind
+// server.Authenticate, the authenticated caller is recorded as approver.
+func ApprovalHandler(svc Services) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /approvals", func(w http.ResponseWriter, r *http.Request) {
+		tenantId := r.URL.Query().Get("tenant_id")
+		if tenantId == "" {
+			http.Error(w, "tenant_id is required", http.StatusBadRequest)
+			return
+		}
+
+		records, err := svc.Storage.ListCredentials(tenantId, StatusPendingApproval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		pending := make([]PendingApproval, 0, len(records))
+		for _, record := range records {
+			pending = append(pending, PendingApproval{
+				TenantId:        record.TenantId,
+				RequestId:       record.RequestId,
+				ConfigurationId: record.ConfigurationId,
+				Created:         record.Created,
+			})
+		}
+		writeJson(w, http.StatusOK, pending)
+	})
+
+	mux.HandleFunc("POST /approvals/{tenant}/{request}/{decision}", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the decision is the one of the path and the approver the authenticated caller, never the body
+		req := ApprovalRequest{
+			Decision: r.PathValue("decision"),
+			Approver: server.Caller(r.Context()),
+			Reason:   body.Reason,
+		}
+		req.TenantId = r.PathValue("tenant")
+		req.RequestId = r.PathValue("request")
+
+		reply := ApprovalReply{Reply: common.Reply{TenantId: req.TenantId, RequestId: req.RequestId}}
+		record, err := decide(svc, req)
+		if record != nil {
+			reply.Status = record.Status
+		}
+		if err != nil {
+			reply.Error = approvalError(err)
+			writeJson(w, reply.Error.Status, reply)
+			return
+		}
+		writeJson(w, http.StatusOK, reply)
+	})
+
+	return mux
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,67 @@
+package issuance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/server"
+)
+
+func TestApprovalHandlerDecide(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		token        string
+		wantStatus   int
+		wantRecord   string
+		wantApprover string
+	}{
+		{name: "unauthenticated", path: "approve", body: `{}`, wantStatus: http.StatusUnauthorized, wantRecord: StatusPendingApproval},
+		{name: "approve", path: "approve", body: `{"reason":"ok"}`, token: "a-token", wantStatus: http.StatusOK, wantRecord: StatusPrepared, wantApprover: "alice"},
+		{name: "empty body", path: "reject", token: "a-token", wantStatus: http.StatusOK, wantRecord: StatusRejected, wantApprover: "alice"},
+		{
+			name:         "body does not override path or caller",
+			path:         "reject",
+			body:         `{"decision":"approve","approver":"mallory"}`,
+			token:        "a-token",
+			wantStatus:   http.StatusOK,
+			wantRecord:   StatusRejected,
+			wantApprover: "alice",
+		},
+		{name: "unknown decision", path: "maybe", body: `{}`, token: "a-token", wantStatus: http.StatusBadRequest, wantRecord: StatusPendingApproval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewDummyStorage(0)
+			if err := storage.AddCredential(&CredentialRecord{Code: "code", TenantId: "t", RequestId: "r", Status: StatusPendingApproval}); err != nil {
+				t.Fatal(err)
+			}
+			h := server.Authenticate(map[string]string{"alice": "a-token"}, ApprovalHandler(Services{Storage: storage}))
+
+			r := httptest.NewRequest(http.MethodPost, "/approvals/t/r/"+tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			record, err := storage.GetCredential("code")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.Status != tt.wantRecord {
+				t.Errorf("status = %s, want %s", record.Status, tt.wantRecord)
+			}
+			if record.Approver != tt.wantApprover {
+				t.Errorf("approver = %q, want %q", record.Approver, tt.wantApprover)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+)
+
+type AuditQueryRequest struct {
+	common.Request
+	Filter audit.Filter `json:"filter"`
+}
+
+type AuditQueryReply struct {
+	common.Reply
+	audit.QueryResult
+}
+
+// AuditQuery answers queries of the audit trail on AUDIT_QUERY_TOPIC.
+func AuditQuery(ctx context.Context, conf config.Config, trail *audit.Trail) {
+	if conf.Audit.QueryTopic == "" {
+		return
+	}
+
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+		cloudeventprovider.ConnectionTypeRep,
+		conf.Audit.QueryTopic,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("serving audit queries", "subject", conf.Audit.QueryTopic)
+	serve(ctx, client, conf.Nats.TimeoutInSec, auditQueryHandler(trail))
+}
+
+func auditQueryHandler(trail *audit.Trail) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req AuditQueryRequest
+		if err := json.Unmarshal(event.DataEncoded, &req); err != nil {
+			slog.Error("invalid audit query", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		result := trail.Result(req.Filter)
+		logging.Request(req.TenantId, req.RequestId).Info("audit query answered", "entries", len(result.Entries), "valid", result.Valid)
+
+		return replyEvent(AuditQueryReply{
+			Reply: common.Reply{
+				TenantId:  req.TenantId,
+				RequestId: req.RequestId,
+				GroupId:   req.GroupId,
+			},
+			QueryResult: result,
+		})
+	}
+}
+
+// auditStep appends a step to the audit trail. A failing trail is logged, the issuance itself goes on.
+func auditStep(trail *audit.Trail, logger *slog.Logger, e audit.Entry) {
+	if err := trail.Record(e); err != nil {
+		logger.Error("audit entry could not be recorded", "step", e.Step, "error", err)
+	}
+}
@@ -0,0 +1,52 @@
+package issuance
+
+import (
+	"context"
+	"errors"
+
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/claims"
+)
+
+var ErrNoClaimsProvider = errors.New("no claims provider configured for the credential configuration")
+
+// resolveClaims asks the provider of the record for the credential subject referenced in the offer request.
+func resolveClaims(ctx context.Context, resolver *claims.Resolver, record *CredentialRecord) (map[string]interface{}, error) {
+	provider := resolver.For(record.TenantId, record.ConfigurationId)
+	if provider == nil {
+		return nil, ErrNoClaimsProvider
+	}
+
+	return provider.Claims(ctx, claims.Request{
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		Reference:       record.Reference,
+	})
+}
+
+// mappingError reports claims the mapping rules cannot be applied to, status tells whether the claims
+// came from the requester or from a claims provider.
+func mappingError(err error, status int) *common.Error {
+	return &common.Error{
+		Id:     "claim-mapping-error",
+		Status: status,
+		Msg:    err.Error(),
+	}
+}
+
+func claimsError(err error) *common.Error {
+	if errors.Is(err, claims.ErrNotFound) {
+		return &common.Error{
+			Id:     "claims-not-found",
+			Status: 404,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "claims-unavailable",
+		Status: 502,
+		Msg:    err.Error(),
+	}
+}
@@ -0,0 +1,56 @@
+package issuance
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+)
+
+// configurationHandler prepares the credentials of one advertised credential configuration.
+type configurationHandler struct {
+	id            string
+	configuration credential.CredentialConfiguration
+}
+
+// subjectHandlers holds the configurations served on one NATS subject, keyed by configuration id.
+type subjectHandlers struct {
+	subject  string
+	handlers map[string]*configurationHandler
+}
+
+func (s subjectHandlers) ids() []string {
+	ids := make([]string, 0, len(s.handlers))
+	for id := range s.handlers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// subjects groups all credential configurations of the registration by their subject, so that
+// every subject is subscribed once no matter how many configurations share it.
+func subjects(prefix string) []subjectHandlers {
+	bySubject := make(map[string]subjectHandlers)
+
+	for id, c := range metadata.Registration.Issuer.CredentialConfigurationsSupported {
+		subject := prefix + c.Subject
+
+		s, ok := bySubject[subject]
+		if !ok {
+			s = subjectHandlers{subject: subject, handlers: make(map[string]*configurationHandler)}
+			bySubject[subject] = s
+		}
+		s.handlers[id] = &configurationHandler{id: id, configuration: c}
+	}
+
+	result := make([]subjectHandlers, 0, len(bySubject))
+	for _, s := range bySubject {
+		result = append(result, s)
+	}
+	slices.SortFunc(result, func(a, b subjectHandlers) int {
+		return strings.Compare(a.subject, b.subject)
+	})
+	return result
+}
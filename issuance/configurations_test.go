@@ -0,0 +1,31 @@
+package issuance
+
+import (
+	"testing"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+)
+
+func TestPublishedSubjectsAreServed(t *testing.T) {
+	for _, prefix := range []string{"", "staging."} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			conf := config.Default()
+			conf.SubjectPrefix = prefix
+
+			published := metadata.ForTenant(conf, config.TenantConfig{Id: metadata.DefaultTenant}).Issuer.CredentialConfigurationsSupported
+			served := 0
+			for _, s := range subjects(conf.SubjectPrefix) {
+				for id := range s.handlers {
+					served++
+					if got := published[id].Subject; got != s.subject {
+						t.Errorf("%s is published on %q but served on %q", id, got, s.subject)
+					}
+				}
+			}
+			if served != len(published) {
+				t.Errorf("%d configurations served, %d published", served, len(published))
+			}
+		})
+	}
+}
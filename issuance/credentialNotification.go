@@ -0,0 +1,137 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+)
+
+// Notification events defined by OID4VCI.
+const (
+	NotificationAccepted = "credential_accepted"
+	NotificationFailure  = "credential_failure"
+	NotificationDeleted  = "credential_deleted"
+)
+
+var notificationStates = map[string]struct {
+	status    string
+	eventType string
+}{
+	NotificationAccepted: {StatusAccepted, events.TypeCredentialAccepted},
+	NotificationFailure:  {StatusFailed, events.TypeCredentialFailure},
+	NotificationDeleted:  {StatusDeleted, events.TypeCredentialDeleted},
+}
+
+// NotificationRequest is the notification a wallet sent for a credential it received.
+type NotificationRequest struct {
+	common.Request
+	NotificationId   string `json:"notification_id"`
+	Event            string `json:"event"`
+	EventDescription string `json:"event_description,omitempty"`
+}
+
+func CredentialNotification(ctx context.Context, conf config.Config, svc Services) {
+	serveSubjects(ctx, conf, ".notification", func(s subjectHandlers) replyFunc {
+		return notificationHandler(svc, s)
+	})
+}
+
+func notificationHandler(svc Services, s subjectHandlers) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req NotificationRequest
+		err := json.Unmarshal(event.DataEncoded, &req)
+
+		if err != nil {
+			slog.Error("invalid notification", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		logger := logging.Request(req.TenantId, req.RequestId)
+		logger.Info("notification received", "event_id", event.ID(), "notification_id", req.NotificationId, "event", req.Event)
+
+		reply := common.Reply{
+			TenantId:  req.TenantId,
+			RequestId: req.RequestId,
+			GroupId:   req.GroupId,
+		}
+
+		state, ok := notificationStates[req.Event]
+		if !ok {
+			reply.Error = &common.Error{
+				Id:     "invalid-notification-request",
+				Status: 400,
+				Msg:    fmt.Sprintf("event %q is not one of %s, %s, %s", req.Event, NotificationAccepted, NotificationFailure, NotificationDeleted),
+			}
+			return replyEvent(reply)
+		}
+
+		record, err := svc.Storage.GetCredentialByNotification(req.NotificationId)
+		if err == nil && (s.handlers[record.ConfigurationId] == nil || (req.TenantId != "" && req.TenantId != record.TenantId)) {
+			err = ErrNotFound
+		}
+		if err != nil {
+			logger.Warn("unknown notification id", "notification_id", req.NotificationId)
+			reply.Error = &common.Error{
+				Id:     "invalid-notification-id",
+				Status: 400,
+				Msg:    "no issued credential found for notification id",
+			}
+			return replyEvent(reply)
+		}
+
+		logger = logger.With(logging.KeyConfigurationId, record.ConfigurationId)
+
+		// only delivered credentials change with notifications, a revoked one stays revoked
+		record.Status = state.status
+		err = svc.Storage.TransitionCredential(record, StatusIssued, StatusAccepted)
+		if errors.Is(err, ErrInvalidTransition) {
+			logger.Warn("notification does not apply to the credential", "error", err)
+			reply.Error = &common.Error{
+				Id:     "invalid-notification-state",
+				Status: 409,
+				Msg:    err.Error(),
+			}
+			return replyEvent(reply)
+		}
+		if err != nil {
+			logger.Error("notification could not be recorded", "error", err)
+			reply.Error = &common.Error{
+				Id:     "notification-error",
+				Status: 500,
+				Msg:    err.Error(),
+			}
+			return replyEvent(reply)
+		}
+
+		emit(ctx, svc.Publisher, logger, events.Event{
+			Type:            state.eventType,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  record.NotificationId,
+			Description:     req.EventDescription,
+		})
+
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepNotification,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  record.NotificationId,
+			Source:          event.Source(),
+			Detail:          req.Event,
+		})
+
+		logger.Info("credential status updated", "status", record.Status)
+		return replyEvent(reply)
+	}
+}
@@ -5,22 +5,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/cloudevents/sdk-go/v2/event"
-	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
 	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/limits"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/policy"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/signer"
 	issuance "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/google/uuid"
 )
 
-func signCredential(credential map[string]interface{}, tenantId string, signerkey string, url string, origin string, nonce string, format string) (any, error) {
+func signCredential(ctx context.Context, signerClient *signer.Client, credential map[string]interface{}, key metadata.SigningKey, nonce string, format string) (any, error) {
 
 	env := os.Getenv("DUMMYCONTENTSIGNER_STATUS")
 	var err error
@@ -34,9 +38,10 @@ func signCredential(credential map[string]interface{}, tenantId string, signerke
 		}
 	}
 
-	credential["namespace"] = tenantId
-	credential["group"] = ""
-	credential["key"] = signerkey
+	credential["namespace"] = key.Namespace
+	credential["group"] = key.Group
+	credential["key"] = key.Key
+	credential["algorithm"] = key.Algorithm
 	credential["status"] = status
 	credential["nonce"] = nonce
 
@@ -45,34 +50,17 @@ func signCredential(credential map[string]interface{}, tenantId string, signerke
 		return nil, err
 	}
 
-	r, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	r.Header.Add("Content-Type", "application/json")
-	r.Header.Add("x-origin", origin)
-
+	b, err := signerClient.Sign(ctx, body)
 	if err != nil {
 		return nil, err
 	}
 
-	client := &http.Client{}
-	res, err := client.Do(r)
-	if err != nil || res.StatusCode != 200 {
-		if res != nil && res.StatusCode != 200 {
-			b, _ := io.ReadAll(res.Body)
-			return nil, errors.New("signer service returned no 200: " + string(b))
-		}
-		return nil, err
-	}
-
-	defer res.Body.Close()
-
-	b, err := io.ReadAll(res.Body)
-
 	if format == "ldp_vc" {
 		var post map[string]interface{}
 
-		derr := json.NewDecoder(bytes.NewBuffer(b)).Decode(&post)
+		err := json.NewDecoder(bytes.NewBuffer(b)).Decode(&post)
 
-		if derr != nil {
+		if err != nil {
 			return nil, err
 		}
 
@@ -86,96 +74,339 @@ func signCredential(credential map[string]interface{}, tenantId string, signerke
 	return strings.Trim(strings.Replace(string(b), "\"", "", -1), "\n"), nil
 }
 
-func CredentialReply(conf config.Config, storage IssuanceStorage) {
+// CredentialReply answers .issue requests, signing the credentials with signerClient.
+func CredentialReply(ctx context.Context, conf config.Config, svc Services, signerClient *signer.Client) {
+	serveSubjects(ctx, conf, ".issue", func(s subjectHandlers) replyFunc {
+		return issueHandler(conf, svc, signerClient, s)
+	})
+}
+
+func issueHandler(conf config.Config, svc Services, signerClient *signer.Client, s subjectHandlers) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req issuance.IssuanceModuleReq
+		var ext issueRequestExtension
+		err := json.Unmarshal(event.DataEncoded, &req)
+		if err == nil {
+			err = json.Unmarshal(event.DataEncoded, &ext)
+		}
 
-	client, err := cloudeventprovider.New(
-		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
-		cloudeventprovider.ConnectionTypeRep,
-		metadata.Registration.Issuer.CredentialConfigurationsSupported[metadata.Credential_Identifier].Subject+".issue",
-	)
-	if err != nil {
-		panic(err)
-	}
+		if err != nil {
+			slog.Error("invalid issue request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
 
-	for {
-		if err := client.ReplyCtx(context.Background(), func(ctx context.Context, event event.Event) (*event.Event, error) {
-			log.Printf("Event received %+v", event)
-			var req issuance.IssuanceModuleReq
-			err := json.Unmarshal(event.DataEncoded, &req)
+		logger := logging.Request(req.TenantId, req.RequestId)
+		logger.Info("issue request received", "event_id", event.ID(), "event_type", event.Type(), "format", req.Format)
+		logger.Debug("issue request", "code", req.Code, "holder", req.Holder)
+
+		reply := issueReply{IssuanceModuleRep: issuance.IssuanceModuleRep{
+			Reply: common.Reply{
+				TenantId:  req.TenantId,
+				RequestId: req.RequestId,
+				GroupId:   req.GroupId,
+			},
+			Format: req.Format,
+		}}
+
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:      audit.StepIssueReceived,
+			TenantId:  req.TenantId,
+			RequestId: req.RequestId,
+			Source:    event.Source(),
+		})
+
+		record, err := svc.Storage.GetCredential(req.Code)
+
+		// a code prepared for another tenant or for a configuration of another subject is not known here
+		if err == nil && (s.handlers[record.ConfigurationId] == nil || (req.TenantId != "" && req.TenantId != record.TenantId)) {
+			err = ErrNotFound
+		}
 
-			if err != nil {
-				return nil, err
+		if err != nil {
+			logger.Warn("no credential found", "error", err)
+			reply.Error = &common.Error{
+				Id:     "credential-load-error",
+				Status: 400,
+				Msg:    err.Error(),
 			}
+			auditStep(svc.Trail, logger, audit.Entry{
+				Step:      audit.StepIssueFailed,
+				TenantId:  req.TenantId,
+				RequestId: req.RequestId,
+				Error:     reply.Error.Id,
+			})
+			return replyEvent(reply)
+		}
 
-			reply := issuance.IssuanceModuleRep{
-				Reply: common.Reply{
-					TenantId:  req.TenantId,
-					RequestId: req.RequestId,
-					GroupId:   req.GroupId,
-				},
-				Format: req.Format,
+		logger = logger.With(logging.KeyConfigurationId, record.ConfigurationId)
+
+		failed := func(e *common.Error) {
+			reply.Error = e
+			emit(ctx, svc.Publisher, logger, events.Event{
+				Type:            events.TypeCredentialFailed,
+				TenantId:        record.TenantId,
+				RequestId:       record.RequestId,
+				ConfigurationId: record.ConfigurationId,
+				Format:          reply.Format,
+				Reason:          e.Id,
+			})
+			auditStep(svc.Trail, logger, audit.Entry{
+				Step:            audit.StepIssueFailed,
+				TenantId:        record.TenantId,
+				RequestId:       record.RequestId,
+				ConfigurationId: record.ConfigurationId,
+				Error:           e.Id,
+			})
+		}
+
+		tenant, err := metadata.Enabled(conf, record.TenantId, record.ConfigurationId)
+		if err != nil {
+			logger.Warn("issuance rejected", "error", err)
+			failed(tenantError(err))
+			return replyEvent(reply)
+		}
+
+		switch record.Status {
+		case StatusPendingApproval:
+			logger.Info("credential is pending approval")
+			reply.Error = &common.Error{
+				Id:     "issuance_pending",
+				Status: 400,
+				Msg:    "credential is waiting for approval",
 			}
+			return replyEvent(reply)
+		case StatusRejected:
+			logger.Warn("credential was rejected", "approver", record.Approver)
+			failed(&common.Error{
+				Id:     "credential-rejected",
+				Status: 403,
+				Msg:    "credential was rejected by an approver",
+			})
+			return replyEvent(reply)
+		case StatusExpired, StatusCancelled:
+			logger.Warn("offer is no longer valid", "status", record.Status)
+			failed(&common.Error{
+				Id:     "offer-" + record.Status,
+				Status: 400,
+				Msg:    "the credential offer was " + record.Status,
+			})
+			return replyEvent(reply)
+		case StatusIssued, StatusAccepted, StatusFailed, StatusDeleted, StatusRevoked:
+			logger.Warn("offer was already redeemed", "status", record.Status)
+			failed(&common.Error{
+				Id:     "offer-redeemed",
+				Status: 400,
+				Msg:    "the credential offer was already redeemed",
+			})
+			return replyEvent(reply)
+		}
 
-			cred, err := storage.GetCredential(req.Code)
+		// the policy sees the pushed claims or, for claims resolved at pickup, the reference
+		payload, _ := record.Credential["credentialSubject"].(map[string]interface{})
+		if record.Reference != nil {
+			payload = record.Reference
+		}
+		err = svc.Policy.Evaluate(policy.Input{
+			Operation:     policy.OpIssue,
+			Tenant:        tenant.Id,
+			Configuration: record.ConfigurationId,
+			Payload:       payload,
+			Holder:        req.Holder,
+			Request: map[string]string{
+				"request_id": req.RequestId,
+				"group_id":   req.GroupId,
+				"source":     event.Source(),
+				"event_type": event.Type(),
+				"format":     req.Format,
+			},
+		})
+		if err != nil {
+			logger.Warn("issue request denied", "error", err)
+			failed(policyError(err))
+			return replyEvent(reply)
+		}
 
-			if err != nil || cred == nil {
-				log.Printf("Error %+v", err)
-				reply.Error = &common.Error{
-					Id:     "no credential found",
-					Status: 400,
-					Msg:    err.Error(),
-				}
+		if err := svc.Limiter.Allow(limits.OpIssue, tenant.Id, record.ConfigurationId); err != nil {
+			logger.Warn("issue request limited", "error", err)
+			failed(limitError(err))
+			return replyEvent(reply)
+		}
+		// the quota slot reserved by Allow is counted once the credential is delivered, freed otherwise
+		delivered := false
+		defer func() {
+			if !delivered {
+				svc.Limiter.Release(tenant.Id)
 			}
+		}()
+
+		encryption := ext.CredentialResponseEncryption
+		if encryption == nil && tenant.RequireEncryption {
+			err = ErrEncryptionRequired
+		} else if encryption != nil {
+			err = encryption.validate()
+		}
+		if err != nil {
+			logger.Warn("invalid credential response encryption", "error", err)
+			failed(&common.Error{
+				Id:     "invalid-encryption-parameters",
+				Status: 400,
+				Msg:    err.Error(),
+			})
+			return replyEvent(reply)
+		}
 
-			if req.Format == "" {
-				reply.Format = cred["format"].(string)
+		key, err := metadata.SigningKeyFor(conf, record.TenantId, record.ConfigurationId)
+		if err != nil {
+			logger.Error("no signing key for credential configuration", "error", err)
+			failed(&common.Error{
+				Id:     "signing-key-error",
+				Status: 500,
+				Msg:    err.Error(),
+			})
+			return replyEvent(reply)
+		}
+
+		cred := record.Credential
+
+		if record.Reference != nil {
+			subject, err := resolveClaims(ctx, svc.Claims, record)
+			if err != nil {
+				logger.Error("claims could not be resolved", "error", err)
+				failed(claimsError(err))
+				return replyEvent(reply)
 			}
+			subject, err = svc.Mapper.Apply(record.TenantId, record.ConfigurationId, subject)
+			if err != nil {
+				logger.Error("claims could not be mapped", "error", err)
+				failed(mappingError(err, 502))
+				return replyEvent(reply)
+			}
+			cred["credentialSubject"] = subject
+		}
+
+		if req.Format == "" {
+			reply.Format, _ = cred["format"].(string)
+		}
+
+		if req.Holder != "" {
+			cred["holder"] = req.Holder
+		}
+
+		c, err := signCredential(ctx, signerClient, cred, key, req.Code, reply.Format)
+
+		if err != nil {
+			logger.Error("credential signing failed", "error", err)
+			failed(signError(err))
+			return replyEvent(reply)
+		}
+
+		if c == nil {
+			failed(&common.Error{
+				Id:     "credential-load-error",
+				Status: 400,
+				Msg:    "no content could be signed",
+			})
+			return replyEvent(reply)
+		}
 
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepCredentialSigned,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			CredentialHash:  audit.CredentialHash(c),
+			Detail:          key.Namespace + "/" + key.Group + "/" + key.Key,
+		})
+
+		notificationId := uuid.NewString()
+
+		// the credential is only set on the reply once it can be delivered as requested
+		credential := c
+		if encryption != nil {
+			jwe, err := encryptResponse(map[string]any{"credential": c, "notification_id": notificationId}, encryption)
 			if err != nil {
-				log.Printf("Error %+v", err)
-				reply.Error = &common.Error{
-					Id:     "credential-load-error",
+				logger.Error("credential response could not be encrypted", "error", err)
+				failed(&common.Error{
+					Id:     "invalid-encryption-parameters",
 					Status: 400,
 					Msg:    err.Error(),
-				}
-			} else {
-
-				if req.Holder != "" {
-					cred["holder"] = req.Holder
-				}
-
-				c, err := signCredential(cred, req.TenantId, conf.SignerKey, conf.SignerUrl, conf.Origin, req.Code, reply.Format)
-
-				if err != nil {
-					return nil, err
-				}
-
-				if c == nil {
-					reply.Error = &common.Error{
-						Id:     "credential-load-error",
-						Status: 400,
-						Msg:    err.Error(),
-					}
-				} else {
-					reply.Credential = c
-				}
+				})
+				return replyEvent(reply)
 			}
+			credential = jwe
+			reply.Encrypted = true
+		}
 
-			b, err := json.Marshal(reply)
+		if err := issued(svc.Storage, record, notificationId); err != nil {
+			logger.Warn("issuance could not be recorded", "error", err)
+			failed(issuedError(err))
+			return replyEvent(reply)
+		}
+		reply.Credential = credential
+		svc.Limiter.Issued(tenant.Id)
+		delivered = true
+		reply.NotificationId = notificationId
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepCredentialDelivered,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  notificationId,
+			CredentialHash:  audit.CredentialHash(c),
+		})
+
+		logger.Info("credential issued", "format", reply.Format, "key", key.Key, "algorithm", key.Algorithm, "encrypted", reply.Encrypted)
+		emit(ctx, svc.Publisher, logger, events.Event{
+			Type:            events.TypeCredentialIssued,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  notificationId,
+			Format:          reply.Format,
+		})
+
+		return replyEvent(reply)
+	}
+}
 
-			if err != nil {
-				return nil, err
-			}
+// issued records the delivery of the credential under the id the wallet uses for its notifications.
+// It fails if the offer was redeemed, cancelled or expired in the meantime, the credential must not be
+// delivered then.
+func issued(storage IssuanceStorage, record *CredentialRecord, notificationId string) error {
+	record.Status = StatusIssued
+	record.NotificationId = notificationId
 
-			event, err = cloudeventprovider.NewEvent("test-issuer", "dummycontentsigner", b)
-			if err != nil {
-				return nil, err
-			}
+	return storage.TransitionCredential(record, StatusPrepared)
+}
 
-			return &event, nil
-		}); err != nil {
-			log.Printf("%+v", err)
-			continue
+func issuedError(err error) *common.Error {
+	if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrNotFound) {
+		return &common.Error{
+			Id:     "offer-no-longer-valid",
+			Status: 409,
+			Msg:    err.Error(),
 		}
 	}
+
+	return &common.Error{
+		Id:     "issuance-not-recorded",
+		Status: 500,
+		Msg:    err.Error(),
+	}
+}
+
+func signError(err error) *common.Error {
+	if errors.Is(err, signer.ErrUnavailable) {
+		return &common.Error{
+			Id:     "signer-unavailable",
+			Status: 503,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "credential-sign-error",
+		Status: 502,
+		Msg:    err.Error(),
+	}
 }
@@ -3,21 +3,32 @@ package issuance
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	"time"
 
 	"github.com/cloudevents/sdk-go/v2/event"
 	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
 	messaging "github.com/eclipse-xfsc/nats-message-library"
 	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/limits"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/policy"
 	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
 	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
 	"github.com/google/uuid"
 )
 
-func createCredential(code string, payload map[string]interface{}, storage IssuanceStorage, identifier string) error {
+// createCredential stores the credential to be signed at pickup in the given status, together with the
+// offer carrying the code. With lazy claims the payload is kept as reference for the claims provider
+// instead of becoming the credential subject.
+func (h *configurationHandler) createCredential(code string, offer string, req messaging.IssuanceRequest, tenant config.TenantConfig, lazy bool, status string, storage IssuanceStorage) (map[string]interface{}, error) {
 	var credJson = make(map[string]interface{})
 
 	credJson = map[string]interface{}{
@@ -29,145 +40,335 @@ func createCredential(code string, payload map[string]interface{}, storage Issua
 		"issuanceDate": "2022-06-02T17:24:05.032533+03:00",
 	}
 
-	credJson["credentialSubject"] = payload
+	var reference map[string]interface{}
+	if lazy {
+		reference = map[string]interface{}{}
+		maps.Copy(reference, req.Payload)
+	} else {
+		credJson["credentialSubject"] = req.Payload
+	}
 
-	credJson["issuer"] = metadata.Registration.Issuer.CredentialIssuer
+	credJson["issuer"] = metadata.IssuerUrl(tenant)
 
-	if identifier == metadata.Credential_Identifier2 {
-		credJson["format"] = "vc+sd-jwt"
-		credJson["type"] = []string{"VerifiableCredential", "SDJWTCredential"}
-	} else {
-		credJson["format"] = "ldp_vc"
+	credJson["format"] = h.configuration.Format
+	credJson["type"] = []string{"VerifiableCredential", h.id}
+
+	if h.configuration.Format == "ldp_vc" {
 		credJson["issuanceDate"] = time.Now().Format(time.RFC3339)
-		credJson["type"] = []string{"VerifiableCredential", "DeveloperCredential"}
 	}
 
-	err := storage.AddCredential(code, credJson)
+	err := storage.AddCredential(&CredentialRecord{
+		Code:            code,
+		TenantId:        req.TenantId,
+		RequestId:       req.RequestId,
+		ConfigurationId: h.id,
+		Credential:      credJson,
+		Reference:       reference,
+		Offer:           offer,
+		Status:          status,
+	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return credJson, nil
 }
 
-func CredentialRequest(conf config.Config, storage IssuanceStorage) {
+func CredentialRequest(ctx context.Context, conf config.Config, svc Services) {
 
-	authclient, _ := cloudeventprovider.New(
+	authclient, err := cloudeventprovider.New(
 		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
 		cloudeventprovider.ConnectionTypeReq,
 		issumsg.TopicOffering,
 	)
+	if err != nil {
+		panic(err)
+	}
+	defer authclient.Close()
 
-	client, _ := cloudeventprovider.New(
-		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
-		cloudeventprovider.ConnectionTypeRep,
-		metadata.Registration.Issuer.CredentialConfigurationsSupported[metadata.Credential_Identifier].Subject+".request",
-	)
+	replies := newReplayCache(conf.Idempotency.Window)
 
-	for {
-		if err := client.ReplyCtx(context.Background(), func(ctx context.Context, event event.Event) (*event.Event, error) {
+	serveSubjects(ctx, conf, ".request", func(s subjectHandlers) replyFunc {
+		return requestHandler(conf, svc, authclient, replies, s)
+	})
+}
 
-			var req messaging.IssuanceRequest
-			err := json.Unmarshal(event.DataEncoded, &req)
+func requestHandler(conf config.Config, svc Services, authclient *cloudeventprovider.CloudEventProviderClient, replies *replayCache, s subjectHandlers) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
 
-			if err != nil {
-				return nil, err
-			}
+		var req messaging.IssuanceRequest
+		err := json.Unmarshal(event.DataEncoded, &req)
 
-			reply := messaging.IssuanceReply{
-				Reply: common.Reply{
-					TenantId:  req.TenantId,
-					RequestId: req.RequestId,
-					GroupId:   req.GroupId,
-				},
-			}
+		var fields deliveryFields
+		if err == nil {
+			err = json.Unmarshal(event.DataEncoded, &fields)
+		}
 
-			nonce := uuid.NewString()
-			offerReq := issumsg.OfferingURLReq{
-				Request: common.Request{
-					TenantId:  req.TenantId,
-					RequestId: req.RequestId,
-					GroupId:   reply.GroupId,
-				},
-				Params: issumsg.AuthorizationReq{
-					CredentialConfigurations: []credential.CredentialConfigurationIdentifier{
-						credential.CredentialConfigurationIdentifier{
-							Id: req.Identifier,
-						},
-					},
-					GrantType: "urn:ietf:params:oauth:grant-type:pre-authorized_code",
-					TwoFactor: issumsg.TwoFactor{
-						Enabled: false,
-					},
-					Nonce: nonce,
-				},
-			}
+		if err != nil {
+			slog.Error("invalid issuance request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
 
-			r, _ := json.Marshal(offerReq)
+		logger := logging.Request(req.TenantId, req.RequestId).With(logging.KeyConfigurationId, req.Identifier)
+		logger.Info("issuance request received", "event_id", event.ID(), "event_type", event.Type())
+		logger.Debug("issuance request", "payload", req.Payload)
 
-			authevent, err := cloudeventprovider.NewEvent("test-issuer", issumsg.EventTypeOffering, r)
+		entry := audit.Entry{
+			Step:            audit.StepRequestReceived,
+			TenantId:        req.TenantId,
+			RequestId:       req.RequestId,
+			ConfigurationId: req.Identifier,
+			Source:          event.Source(),
+		}
+		auditStep(svc.Trail, logger, entry)
+		entry.Source = ""
+
+		reply := messaging.IssuanceReply{
+			Reply: common.Reply{
+				TenantId:  req.TenantId,
+				RequestId: req.RequestId,
+				GroupId:   req.GroupId,
+			},
+		}
 
-			if err != nil {
-				reply.Error = &common.Error{
-					Id:     "auth-req-error",
-					Status: 400,
-					Msg:    err.Error(),
-				}
+		// the reply is audited once it is complete, failures with their error id
+		defer func() {
+			if reply.Error != nil {
+				entry.Step = audit.StepRequestFailed
+				entry.Error = reply.Error.Id
+				auditStep(svc.Trail, logger, entry)
 			}
+		}()
 
-			authrep, err := authclient.RequestCtx(ctx, authevent)
+		replayed, reserved, err := replies.reserve(req)
+		if err != nil {
+			logger.Warn("duplicate issuance request rejected", "error", err)
+			reply.Error = idempotencyError(err)
+			return replyEvent(reply)
+		}
+		if replayed != nil {
+			logger.Info("issuance request repeated, replaying the original reply")
+			entry.Step = audit.StepRequestReplayed
+			auditStep(svc.Trail, logger, entry)
+			return replyEvent(*replayed)
+		}
+		if reserved {
+			defer func() { replies.complete(req, reply) }()
+		}
 
-			if err != nil {
-				reply.Error = &common.Error{
-					Id:     "credential-req-error",
-					Status: 400,
-					Msg:    err.Error(),
-				}
+		handler, ok := s.handlers[req.Identifier]
+		if !ok {
+			logger.Warn("credential configuration is not served on this subject", "subject", s.subject)
+			reply.Error = &common.Error{
+				Id:     "unknown-configuration",
+				Status: 400,
+				Msg:    fmt.Sprintf("credential configuration %q is not served on %s, expected one of %v", req.Identifier, s.subject, s.ids()),
 			}
+			return replyEvent(reply)
+		}
 
-			if authrep != nil {
-
-				var resp issumsg.OfferingURLResp
-
-				err = json.Unmarshal(authrep.Data(), &resp)
-
-				if err == nil {
-					err = createCredential(resp.Code, req.Payload, storage, req.Identifier)
-				}
-
-				if err != nil {
-					reply.Error = &common.Error{
-						Id:     "credential-req-error",
-						Status: 400,
-						Msg:    err.Error(),
-					}
-				} else {
-					reply.Offer = resp.CredentialOffer
-				}
-			} else {
-				reply.Error = &common.Error{
-					Id:     "credential-req-error",
-					Status: 400,
-					Msg:    "no result",
-				}
-			}
+		if deliveryErr := fields.validate(svc); deliveryErr != nil {
+			logger.Warn("offer cannot be delivered", "error", deliveryErr.Msg)
+			reply.Error = deliveryErr
+			return replyEvent(reply)
+		}
 
-			b, err := json.Marshal(reply)
+		tenant, err := metadata.Enabled(conf, req.TenantId, req.Identifier)
+		if err != nil {
+			logger.Warn("issuance rejected", "error", err)
+			reply.Error = tenantError(err)
+			return replyEvent(reply)
+		}
+
+		err = svc.Policy.Evaluate(policy.Input{
+			Operation:     policy.OpRequest,
+			Tenant:        tenant.Id,
+			Configuration: req.Identifier,
+			Payload:       req.Payload,
+			Request: map[string]string{
+				"request_id": req.RequestId,
+				"group_id":   req.GroupId,
+				"source":     event.Source(),
+				"event_type": event.Type(),
+			},
+		})
+		if err != nil {
+			logger.Warn("issuance request denied", "error", err)
+			reply.Error = policyError(err)
+			return replyEvent(reply)
+		}
+
+		if err := svc.Limiter.Allow(limits.OpRequest, tenant.Id, req.Identifier); err != nil {
+			logger.Warn("issuance request limited", "error", err)
+			reply.Error = limitError(err)
+			return replyEvent(reply)
+		}
 
+		lazy := svc.Claims.For(tenant.Id, req.Identifier) != nil
+		if !lazy {
+			req.Payload, err = svc.Mapper.Apply(tenant.Id, req.Identifier, req.Payload)
 			if err != nil {
-				return nil, err
+				logger.Warn("claims could not be mapped", "error", err)
+				reply.Error = mappingError(err, 400)
+				return replyEvent(reply)
 			}
+		}
 
-			event, err = cloudeventprovider.NewEvent("test-issuer", "dummycontentsigner", b)
-			if err != nil {
-				return nil, err
+		resp, offerErr := requestOffer(ctx, authclient, req.TenantId, req.RequestId, reply.GroupId, req.Identifier)
+		if offerErr != nil {
+			logger.Error("no offer received from issuer service", "error", offerErr.Msg)
+			reply.Error = offerErr
+			return replyEvent(reply)
+		}
+
+		credentialOffer, err := svc.Offers.Reference(resp.CredentialOffer)
+		if err != nil {
+			logger.Error("offer could not be stored by reference", "error", err)
+			reply.Error = offerReferenceError(err)
+			return replyEvent(reply)
+		}
+
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepOfferObtained,
+			TenantId:        req.TenantId,
+			RequestId:       req.RequestId,
+			ConfigurationId: req.Identifier,
+		})
+
+		status := StatusPrepared
+		if requiresApproval(conf, tenant, req.Identifier) {
+			status = StatusPendingApproval
+		}
+		prepared, err := handler.createCredential(resp.Code, credentialOffer.CredentialOffer, req, tenant, lazy, status, svc.Storage)
+		if err != nil {
+			svc.Offers.Remove(credentialOffer.CredentialOffer)
+			logger.Error("credential could not be prepared", "error", err)
+			reply.Error = &common.Error{
+				Id:     "credential-req-error",
+				Status: 400,
+				Msg:    err.Error(),
 			}
+			return replyEvent(reply)
+		}
 
-			return &event, nil
-		}); err != nil {
-			log.Printf("%+v", err)
-			continue
+		logger.Info("credential prepared", "status", status)
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepCredentialPrepared,
+			TenantId:        req.TenantId,
+			RequestId:       req.RequestId,
+			ConfigurationId: req.Identifier,
+			CredentialHash:  audit.CredentialHash(prepared),
+			Detail:          status,
+		})
+		reply.Offer = credentialOffer
+		emit(ctx, svc.Publisher, logger, events.Event{
+			Type:            events.TypeOfferCreated,
+			TenantId:        req.TenantId,
+			RequestId:       req.RequestId,
+			ConfigurationId: req.Identifier,
+			Format:          handler.configuration.Format,
+		})
+
+		deliverOffer(svc, logger, &CredentialRecord{
+			TenantId:        req.TenantId,
+			RequestId:       req.RequestId,
+			ConfigurationId: req.Identifier,
+		}, credentialOffer.CredentialOffer, fields)
+
+		return replyEvent(reply)
+	}
+}
+
+// requestOffer asks the issuer service for a pre-authorized code and the credential offer carrying it.
+func requestOffer(ctx context.Context, authclient *cloudeventprovider.CloudEventProviderClient, tenantId, requestId, groupId, configurationId string) (*issumsg.OfferingURLResp, *common.Error) {
+	offerReq := issumsg.OfferingURLReq{
+		Request: common.Request{
+			TenantId:  tenantId,
+			RequestId: requestId,
+			GroupId:   groupId,
+		},
+		Params: issumsg.AuthorizationReq{
+			CredentialConfigurations: []credential.CredentialConfigurationIdentifier{
+				credential.CredentialConfigurationIdentifier{
+					Id: configurationId,
+				},
+			},
+			GrantType: "urn:ietf:params:oauth:grant-type:pre-authorized_code",
+			TwoFactor: issumsg.TwoFactor{
+				Enabled: false,
+			},
+			Nonce: uuid.NewString(),
+		},
+	}
+
+	r, _ := json.Marshal(offerReq)
+
+	authevent, err := cloudeventprovider.NewEvent("test-issuer", issumsg.EventTypeOffering, r)
+	if err != nil {
+		return nil, &common.Error{
+			Id:     "auth-req-error",
+			Status: 400,
+			Msg:    err.Error(),
+		}
+	}
+
+	authrep, err := authclient.RequestCtx(ctx, authevent)
+	if err != nil {
+		return nil, &common.Error{
+			Id:     "credential-req-error",
+			Status: 400,
+			Msg:    err.Error(),
 		}
 	}
+	if authrep == nil {
+		return nil, &common.Error{
+			Id:     "credential-req-error",
+			Status: 400,
+			Msg:    "no result",
+		}
+	}
+
+	var resp issumsg.OfferingURLResp
+	if err := json.Unmarshal(authrep.Data(), &resp); err != nil {
+		return nil, &common.Error{
+			Id:     "credential-req-error",
+			Status: 400,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &resp, nil
+}
+
+func offerReferenceError(err error) *common.Error {
+	return &common.Error{
+		Id:     "offer-reference-error",
+		Status: 500,
+		Msg:    err.Error(),
+	}
+}
+
+func policyError(err error) *common.Error {
+	return &common.Error{
+		Id:     "policy-denied",
+		Status: 403,
+		Msg:    err.Error(),
+	}
+}
+
+func tenantError(err error) *common.Error {
+	if errors.Is(err, metadata.ErrUnknownTenant) {
+		return &common.Error{
+			Id:     "unknown-tenant",
+			Status: 404,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "configuration-disabled",
+		Status: 403,
+		Msg:    err.Error(),
+	}
 }
@@ -0,0 +1,102 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+)
+
+// RevocationRequest revokes an issued credential, identified by the notification id of its issuance.
+type RevocationRequest struct {
+	common.Request
+	NotificationId string `json:"notification_id"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// CredentialRevocation records revocations and announces them as credential.revoked. The status list
+// entry of the credential is maintained by the signer and not changed here.
+func CredentialRevocation(ctx context.Context, conf config.Config, svc Services) {
+	serveSubjects(ctx, conf, ".revoke", func(s subjectHandlers) replyFunc {
+		return revocationHandler(svc, s)
+	})
+}
+
+func revocationHandler(svc Services, s subjectHandlers) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req RevocationRequest
+		err := json.Unmarshal(event.DataEncoded, &req)
+
+		if err != nil {
+			slog.Error("invalid revocation request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		logger := logging.Request(req.TenantId, req.RequestId)
+		logger.Info("revocation request received", "event_id", event.ID(), "notification_id", req.NotificationId)
+
+		reply := common.Reply{
+			TenantId:  req.TenantId,
+			RequestId: req.RequestId,
+			GroupId:   req.GroupId,
+		}
+
+		record, err := svc.Storage.GetCredentialByNotification(req.NotificationId)
+		if err == nil && (s.handlers[record.ConfigurationId] == nil || req.TenantId != record.TenantId) {
+			err = ErrNotFound
+		}
+		if err != nil {
+			logger.Warn("no issued credential for revocation", "notification_id", req.NotificationId)
+			reply.Error = &common.Error{
+				Id:     "credential-not-found",
+				Status: 404,
+				Msg:    "no issued credential found for notification id",
+			}
+			return replyEvent(reply)
+		}
+
+		logger = logger.With(logging.KeyConfigurationId, record.ConfigurationId)
+
+		if record.Status == StatusRevoked {
+			return replyEvent(reply)
+		}
+
+		record.Status = StatusRevoked
+		if err := svc.Storage.UpdateCredential(record); err != nil {
+			logger.Error("revocation could not be recorded", "error", err)
+			reply.Error = &common.Error{
+				Id:     "revocation-error",
+				Status: 500,
+				Msg:    err.Error(),
+			}
+			return replyEvent(reply)
+		}
+
+		logger.Info("credential revoked", "reason", req.Reason)
+		auditStep(svc.Trail, logger, audit.Entry{
+			Step:            audit.StepCredentialRevoked,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  record.NotificationId,
+			Source:          event.Source(),
+			Detail:          req.Reason,
+		})
+		emit(ctx, svc.Publisher, logger, events.Event{
+			Type:            events.TypeCredentialRevoked,
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			NotificationId:  record.NotificationId,
+			Reason:          req.Reason,
+		})
+
+		return replyEvent(reply)
+	}
+}
@@ -1,31 +1,374 @@
 package issuance
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Issuance states of a credential record.
+const (
+	StatusPrepared = "prepared"
+	StatusIssued   = "issued"
+	StatusAccepted = "accepted"
+	StatusFailed   = "failed"
+	StatusDeleted  = "deleted"
+	StatusRevoked  = "revoked"
+
+	StatusPendingApproval = "pending_approval"
+	StatusRejected        = "rejected"
+	StatusExpired         = "expired"
+	StatusCancelled       = "cancelled"
+)
+
+// unredeemed reports whether the offer of a record in the status can still be picked up.
+func unredeemed(status string) bool {
+	return status == StatusPrepared || status == StatusPendingApproval
+}
+
+// CredentialRecord is a prepared credential waiting to be picked up with its pre-authorized code,
+// together with the state of its issuance. If the claims are resolved at pickup, Reference holds the
+// keys of the subject and the credential has no credentialSubject yet. Offer is the credential offer
+// carrying the code as handed out, by value or by reference.
+type CredentialRecord struct {
+	Code            string
+	TenantId        string
+	RequestId       string
+	ConfigurationId string
+	Credential      map[string]interface{}
+	Reference       map[string]interface{}
+	Offer           string
+	Status          string
+	NotificationId  string
+	Created         time.Time
+	Updated         time.Time
+	// decision of an approver, if the configuration needs approval
+	Approver       string
+	DecisionReason string
+	Decided        time.Time
+	// state of the offer sent by email, if a recipient was given
+	Delivery        string
+	DeliveryError   string
+	DeliveryUpdated time.Time
+}
 
 type IssuanceStorage interface {
-	GetCredential(code string) (map[string]interface{}, error)
-	AddCredential(code string, credential map[string]interface{}) error
+	GetCredential(code string) (*CredentialRecord, error)
+	GetCredentialByNotification(notificationId string) (*CredentialRecord, error)
+	// GetCredentialByRequest returns the latest record prepared for the offer request.
+	GetCredentialByRequest(tenantId string, requestId string) (*CredentialRecord, error)
+	// ListCredentials returns the records of the tenant in the status, an empty status matches all.
+	ListCredentials(tenantId string, status string) ([]*CredentialRecord, error)
+	AddCredential(record *CredentialRecord) error
+	// UpdateCredential stores the state of the record, the prepared credential, reference, offer and delivery are not changed.
+	UpdateCredential(record *CredentialRecord) error
+	// TransitionCredential stores the state of the record like UpdateCredential if the stored record is
+	// still in one of the from statuses, otherwise it fails with ErrInvalidTransition. Read and update
+	// are one step, so that concurrent changes of the status are not overwritten.
+	TransitionCredential(record *CredentialRecord, from ...string) error
+	// UpdateDelivery stores the delivery state of the latest record prepared for the offer request.
+	UpdateDelivery(tenantId string, requestId string, status string, deliveryError string) error
+	// ReplaceCode moves the record to a new pre-authorized code and its offer and restarts its TTL if it
+	// is still in one of the from statuses, otherwise it fails with ErrInvalidTransition like
+	// TransitionCredential.
+	ReplaceCode(code string, newCode string, offer string, from ...string) error
+	// Expire marks unredeemed records older than the TTL as expired and keeps them without credential
+	// for another TTL. Issued records are removed once their retention has passed, all other records
+	// once they are older than the TTL. It returns the affected records in the status they had before.
+	Expire(now time.Time) ([]*CredentialRecord, error)
+	Flush() error
 }
 
+var (
+	ErrNotFound          = errors.New("no credential found for code")
+	ErrInvalidTransition = errors.New("credential status does not allow the change")
+)
+
+type storedCredential struct {
+	record  CredentialRecord
+	expires time.Time
+}
+
+// DummyStorage keeps prepared credentials in memory. Credentials not picked up within TTL are dropped,
+// issued ones are kept for Retention after their issuance. A zero TTL or Retention keeps them forever.
 type DummyStorage struct {
+	TTL           time.Duration
+	Retention     time.Duration
+	mu            sync.Mutex
+	store         map[string]storedCredential
+	notifications map[string]string
+}
+
+func NewDummyStorage(ttl time.Duration) *DummyStorage {
+	return &DummyStorage{TTL: ttl}
+}
+
+// GetCredential returns a copy of the record, callers may modify the credential before signing it.
+func (dummy *DummyStorage) GetCredential(code string) (*CredentialRecord, error) {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	return dummy.get(code)
+}
+
+func (dummy *DummyStorage) GetCredentialByNotification(notificationId string) (*CredentialRecord, error) {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	code, ok := dummy.notifications[notificationId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return dummy.get(code)
+}
+
+func (dummy *DummyStorage) GetCredentialByRequest(tenantId string, requestId string) (*CredentialRecord, error) {
+	records, err := dummy.find(func(r *CredentialRecord) bool {
+		return r.TenantId == tenantId && r.RequestId == requestId
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return records[len(records)-1], nil
+}
+
+func (dummy *DummyStorage) ListCredentials(tenantId string, status string) ([]*CredentialRecord, error) {
+	return dummy.find(func(r *CredentialRecord) bool {
+		return r.TenantId == tenantId && (status == "" || r.Status == status)
+	})
+}
+
+// find returns copies of the matching records that have not expired, oldest first.
+func (dummy *DummyStorage) find(match func(r *CredentialRecord) bool) ([]*CredentialRecord, error) {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	var records []*CredentialRecord
+	for code, item := range dummy.store {
+		if !match(&item.record) {
+			continue
+		}
+		record, err := dummy.get(code)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Created.Before(records[j].Created)
+	})
+	return records, nil
+}
+
+func (dummy *DummyStorage) get(code string) (*CredentialRecord, error) {
+	item, ok := dummy.store[code]
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		if !unredeemed(item.record.Status) {
+			dummy.delete(code)
+			return nil, ErrNotFound
+		}
+		// not yet announced by Expire
+		record := item.record
+		record.Status = StatusExpired
+		record.Credential, record.Reference, record.Offer = nil, nil, ""
+		return &record, nil
+	}
+
+	record := item.record
+	record.Credential = maps.Clone(item.record.Credential)
+	record.Reference = maps.Clone(item.record.Reference)
+	return &record, nil
+}
+
+func (dummy *DummyStorage) delete(code string) {
+	if id := dummy.store[code].record.NotificationId; id != "" {
+		delete(dummy.notifications, id)
+	}
+	delete(dummy.store, code)
+}
+
+func (dummy *DummyStorage) AddCredential(record *CredentialRecord) error {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	if dummy.store == nil {
+		dummy.store = make(map[string]storedCredential)
+		dummy.notifications = make(map[string]string)
+	}
+
+	item := storedCredential{record: *record}
+	item.record.Credential = maps.Clone(record.Credential)
+	item.record.Reference = maps.Clone(record.Reference)
+	if item.record.Status == "" {
+		item.record.Status = StatusPrepared
+	}
+	if item.record.Created.IsZero() {
+		item.record.Created = time.Now()
+	}
+	item.record.Updated = item.record.Created
+	if dummy.TTL > 0 {
+		item.expires = item.record.Created.Add(dummy.TTL)
+	}
+	dummy.store[record.Code] = item
+
+	return nil
+}
+
+func (dummy *DummyStorage) UpdateCredential(record *CredentialRecord) error {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	item, ok := dummy.store[record.Code]
+	if !ok {
+		return ErrNotFound
+	}
+
+	dummy.update(item, record)
+	return nil
+}
+
+func (dummy *DummyStorage) TransitionCredential(record *CredentialRecord, from ...string) error {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	item, ok := dummy.store[record.Code]
+	if !ok {
+		return ErrNotFound
+	}
+
+	status := item.record.Status
+	if !item.expires.IsZero() && time.Now().After(item.expires) && unredeemed(status) {
+		status = StatusExpired
+	}
+	if !slices.Contains(from, status) {
+		return fmt.Errorf("%w, it is %s", ErrInvalidTransition, status)
+	}
+
+	dummy.update(item, record)
+	return nil
+}
+
+func (dummy *DummyStorage) update(item storedCredential, record *CredentialRecord) {
+	previous := item.record
+	item.record = *record
+	item.record.Credential = previous.Credential
+	item.record.Reference = previous.Reference
+	item.record.Offer = previous.Offer
+	item.record.Delivery = previous.Delivery
+	item.record.DeliveryError = previous.DeliveryError
+	item.record.DeliveryUpdated = previous.DeliveryUpdated
+	item.record.Updated = time.Now()
+	if record.Status == StatusIssued && previous.Status != StatusIssued {
+		// the offer TTL no longer applies, the credential may still be accepted or revoked
+		item.expires = time.Time{}
+		if dummy.Retention > 0 {
+			item.expires = item.record.Updated.Add(dummy.Retention)
+		}
+	}
+	dummy.store[record.Code] = item
+
+	if record.NotificationId != "" {
+		dummy.notifications[record.NotificationId] = record.Code
+	}
 }
 
-var store = make(map[string]map[string]interface{})
+func (dummy *DummyStorage) UpdateDelivery(tenantId string, requestId string, status string, deliveryError string) error {
+	record, err := dummy.GetCredentialByRequest(tenantId, requestId)
+	if err != nil {
+		return err
+	}
+
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
+
+	item, ok := dummy.store[record.Code]
+	if !ok {
+		return ErrNotFound
+	}
+
+	item.record.Delivery = status
+	item.record.DeliveryError = deliveryError
+	item.record.DeliveryUpdated = time.Now()
+	dummy.store[record.Code] = item
 
-func (dummy *DummyStorage) GetCredential(code string) (map[string]interface{}, error) {
+	return nil
+}
 
-	_, ok := store[code]
+func (dummy *DummyStorage) ReplaceCode(code string, newCode string, offer string, from ...string) error {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
 
+	item, ok := dummy.store[code]
 	if !ok {
-		return nil, errors.New("no item found for " + code)
+		return ErrNotFound
+	}
+
+	status := item.record.Status
+	if !item.expires.IsZero() && time.Now().After(item.expires) && unredeemed(status) {
+		status = StatusExpired
+	}
+	if !slices.Contains(from, status) {
+		return fmt.Errorf("%w, it is %s", ErrInvalidTransition, status)
+	}
+
+	item.record.Code = newCode
+	item.record.Offer = offer
+	item.record.Updated = time.Now()
+	if dummy.TTL > 0 {
+		item.expires = item.record.Updated.Add(dummy.TTL)
+	}
+	delete(dummy.store, code)
+	dummy.store[newCode] = item
+	if id := item.record.NotificationId; id != "" {
+		dummy.notifications[id] = newCode
 	}
 
-	return store[code], nil
+	return nil
 }
 
-func (dummy *DummyStorage) AddCredential(code string, credential map[string]interface{}) error {
+func (dummy *DummyStorage) Expire(now time.Time) ([]*CredentialRecord, error) {
+	dummy.mu.Lock()
+	defer dummy.mu.Unlock()
 
-	store[code] = credential
+	var expired []*CredentialRecord
+	for code, item := range dummy.store {
+		if item.expires.IsZero() || now.Before(item.expires) {
+			continue
+		}
+
+		record := item.record
+		expired = append(expired, &record)
+
+		if !unredeemed(item.record.Status) {
+			dummy.delete(code)
+			continue
+		}
+
+		item.record.Status = StatusExpired
+		item.record.Credential, item.record.Reference, item.record.Offer = nil, nil, ""
+		item.record.Updated = now
+		item.expires = now.Add(dummy.TTL)
+		dummy.store[code] = item
+	}
+
+	return expired, nil
+}
 
+func (dummy *DummyStorage) Flush() error {
 	return nil
 }
@@ -0,0 +1,106 @@
+package issuance
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransitionCredential(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		ttl     time.Duration
+		created time.Time
+		to      string
+		from    []string
+		wantErr error
+	}{
+		{name: "allowed", stored: StatusIssued, to: StatusAccepted, from: []string{StatusIssued, StatusAccepted}},
+		{name: "same status", stored: StatusAccepted, to: StatusDeleted, from: []string{StatusIssued, StatusAccepted}},
+		{name: "revoked stays revoked", stored: StatusRevoked, to: StatusAccepted, from: []string{StatusIssued, StatusAccepted}, wantErr: ErrInvalidTransition},
+		{name: "redeemed code", stored: StatusIssued, to: StatusIssued, from: []string{StatusPrepared}, wantErr: ErrInvalidTransition},
+		{
+			name:    "expired before announced",
+			stored:  StatusPrepared,
+			ttl:     time.Minute,
+			created: time.Now().Add(-time.Hour),
+			to:      StatusIssued,
+			from:    []string{StatusPrepared},
+			wantErr: ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewDummyStorage(tt.ttl)
+			if err := storage.AddCredential(&CredentialRecord{Code: "code", Status: tt.stored, Created: tt.created}); err != nil {
+				t.Fatal(err)
+			}
+
+			record, err := storage.GetCredential("code")
+			if err != nil {
+				t.Fatal(err)
+			}
+			record.Status = tt.to
+			if err := storage.TransitionCredential(record, tt.from...); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionCredential() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := storage.GetCredential("code")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.to
+			if tt.wantErr != nil {
+				want = tt.stored
+				if tt.ttl > 0 {
+					want = StatusExpired
+				}
+			}
+			if stored.Status != want {
+				t.Errorf("stored status = %s, want %s", stored.Status, want)
+			}
+		})
+	}
+}
+
+func TestTransitionCredentialUnknown(t *testing.T) {
+	storage := NewDummyStorage(0)
+	if err := storage.TransitionCredential(&CredentialRecord{Code: "missing"}, StatusPrepared); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TransitionCredential() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestIssuedRecordOutlivesOfferTTL(t *testing.T) {
+	storage := NewDummyStorage(time.Hour)
+	storage.Retention = 24 * time.Hour
+	if err := storage.AddCredential(&CredentialRecord{Code: "code", Created: time.Now().Add(-59 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := storage.GetCredential("code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Status = StatusIssued
+	if err := storage.TransitionCredential(record, StatusPrepared); err != nil {
+		t.Fatalf("TransitionCredential() error = %v", err)
+	}
+
+	if expired, _ := storage.Expire(time.Now().Add(time.Hour)); len(expired) != 0 {
+		t.Fatalf("Expire() after the offer TTL = %d records, want none", len(expired))
+	}
+
+	record.Status = StatusRevoked
+	if err := storage.TransitionCredential(record, StatusIssued, StatusAccepted); err != nil {
+		t.Fatalf("revoking after the offer TTL: %v", err)
+	}
+
+	if expired, _ := storage.Expire(time.Now().Add(25 * time.Hour)); len(expired) != 1 {
+		t.Fatalf("Expire() after the retention = %d records, want 1", len(expired))
+	}
+	if _, err := storage.GetCredential("code"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCredential() after the retention error = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,93 @@
+package issuance
+
+import (
+	"log/slog"
+	"net/mail"
+
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/delivery"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+)
+
+// deliveryFields are read from the issuance request next to its payload. With a recipient the offer
+// is sent by email in the given locale.
+type deliveryFields struct {
+	Recipient string `json:"recipient,omitempty"`
+	Locale    string `json:"locale,omitempty"`
+}
+
+func (f deliveryFields) validate(svc Services) *common.Error {
+	if f.Recipient == "" {
+		return nil
+	}
+
+	if svc.Mailer == nil {
+		return &common.Error{
+			Id:     "delivery-disabled",
+			Status: 400,
+			Msg:    "offers are not delivered by email, MAIL_HOST is not configured",
+		}
+	}
+
+	if _, err := mail.ParseAddress(f.Recipient); err != nil {
+		return &common.Error{
+			Id:     "invalid-recipient",
+			Status: 400,
+			Msg:    err.Error(),
+		}
+	}
+	return nil
+}
+
+// deliverOffer queues the offer for the recipient and tracks the delivery on the prepared record.
+func deliverOffer(svc Services, logger *slog.Logger, record *CredentialRecord, offer string, f deliveryFields) {
+	if f.Recipient == "" || svc.Mailer == nil {
+		return
+	}
+
+	if err := svc.Storage.UpdateDelivery(record.TenantId, record.RequestId, delivery.StatusPending, ""); err != nil {
+		logger.Error("delivery state could not be stored", "error", err)
+	}
+
+	req := delivery.Request{
+		Data: delivery.Data{
+			TenantId:        record.TenantId,
+			RequestId:       record.RequestId,
+			ConfigurationId: record.ConfigurationId,
+			Locale:          f.Locale,
+			Offer:           offer,
+		},
+		Recipient: f.Recipient,
+	}
+
+	done := func(err error) { delivered(svc, record, err) }
+	if err := svc.Mailer.Enqueue(req, done); err != nil {
+		done(err)
+	}
+}
+
+func delivered(svc Services, record *CredentialRecord, err error) {
+	logger := logging.Request(record.TenantId, record.RequestId).With(logging.KeyConfigurationId, record.ConfigurationId)
+
+	entry := audit.Entry{
+		Step:            audit.StepOfferDelivered,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+	}
+	status, message := delivery.StatusSent, ""
+	if err != nil {
+		logger.Warn("offer could not be delivered by email", "error", err)
+		entry.Step = audit.StepOfferDeliveryFailed
+		entry.Error = err.Error()
+		status, message = delivery.StatusFailed, err.Error()
+	} else {
+		logger.Info("offer delivered by email")
+	}
+
+	if err := svc.Storage.UpdateDelivery(record.TenantId, record.RequestId, status, message); err != nil {
+		logger.Error("delivery state could not be stored", "error", err)
+	}
+	auditStep(svc.Trail, logger, entry)
+}
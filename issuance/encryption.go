@@ -0,0 +1,108 @@
+package issuance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	issuance "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+var ErrEncryptionRequired = errors.New("credential response encryption is required for this tenant")
+
+// responseEncryption are the credential_response_encryption parameters a wallet sends with its credential request.
+type responseEncryption struct {
+	Jwk json.RawMessage `json:"jwk"`
+	Alg string          `json:"alg"`
+	Enc string          `json:"enc"`
+
+	// key is the parsed Jwk, set by validate
+	key jwk.Key
+}
+
+// issueReply extends issuance.IssuanceModuleRep by the fields of this module. With Encrypted set,
+// Credential holds the compact JWE of the credential response instead of the credential.
+type issueReply struct {
+	issuance.IssuanceModuleRep
+	NotificationId string `json:"notification_id,omitempty"`
+	Encrypted      bool   `json:"encrypted,omitempty"`
+}
+
+// issueRequestExtension carries the fields of the issue request not covered by issuance.IssuanceModuleReq.
+type issueRequestExtension struct {
+	CredentialResponseEncryption *responseEncryption `json:"credential_response_encryption,omitempty"`
+}
+
+// validate checks the parameters and parses the wallet key, so that a response that cannot be
+// encrypted is refused before the credential is signed.
+func (p *responseEncryption) validate() error {
+	if len(p.Jwk) == 0 {
+		return errors.New("credential_response_encryption.jwk is missing")
+	}
+	if !slices.Contains(metadata.ResponseEncryptionAlgValues, p.Alg) {
+		return fmt.Errorf("credential_response_encryption.alg %q is not supported, expected one of %v", p.Alg, metadata.ResponseEncryptionAlgValues)
+	}
+	if !slices.Contains(metadata.ResponseEncryptionEncValues, p.Enc) {
+		return fmt.Errorf("credential_response_encryption.enc %q is not supported, expected one of %v", p.Enc, metadata.ResponseEncryptionEncValues)
+	}
+
+	key, err := jwk.ParseKey(p.Jwk)
+	if err != nil {
+		return fmt.Errorf("credential_response_encryption.jwk is invalid: %w", err)
+	}
+	if isPrivate(key) {
+		return errors.New("credential_response_encryption.jwk must be a public key")
+	}
+
+	want := jwa.EC
+	if p.Alg == "RSA-OAEP-256" {
+		want = jwa.RSA
+	}
+	if key.KeyType() != want && !(want == jwa.EC && key.KeyType() == jwa.OKP) {
+		return fmt.Errorf("credential_response_encryption.jwk is a %s key, %s needs a %s key", key.KeyType(), p.Alg, want)
+	}
+	// OKP keys agree on a key with X25519 or X448 only, Ed25519 keys sign
+	if okp, ok := key.(jwk.OKPPublicKey); ok && okp.Crv() != jwa.X25519 && okp.Crv() != jwa.X448 {
+		return fmt.Errorf("credential_response_encryption.jwk is an OKP key of curve %s, %s needs X25519 or X448", okp.Crv(), p.Alg)
+	}
+
+	p.key = key
+	return nil
+}
+
+func isPrivate(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.ECDSAPrivateKey, jwk.RSAPrivateKey, jwk.OKPPrivateKey:
+		return true
+	}
+	return false
+}
+
+// encryptResponse returns the compact JWE of the JSON encoded response for the wallet key.
+func encryptResponse(response any, p *responseEncryption) (string, error) {
+	if p.key == nil {
+		if err := p.validate(); err != nil {
+			return "", err
+		}
+	}
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := jwe.Encrypt(payload,
+		jwe.WithKey(jwa.KeyEncryptionAlgorithm(p.Alg), p.key),
+		jwe.WithContentEncryption(jwa.ContentEncryptionAlgorithm(p.Enc)),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,104 @@
+package issuance
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/x25519"
+)
+
+func TestResponseEncryption(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xPub, xKey, err := x25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecPublic := marshalKey(t, &ecKey.PublicKey)
+	rsaPublic := marshalKey(t, &rsaKey.PublicKey)
+	xPublic := marshalKey(t, xPub)
+
+	tests := []struct {
+		name    string
+		p       responseEncryption
+		wantErr bool
+	}{
+		{name: "ec key", p: responseEncryption{Jwk: ecPublic, Alg: "ECDH-ES", Enc: "A256GCM"}},
+		{name: "rsa key", p: responseEncryption{Jwk: rsaPublic, Alg: "RSA-OAEP-256", Enc: "A128GCM"}},
+		{name: "x25519 key", p: responseEncryption{Jwk: xPublic, Alg: "ECDH-ES", Enc: "A256GCM"}},
+		{name: "missing key", p: responseEncryption{Alg: "ECDH-ES", Enc: "A256GCM"}, wantErr: true},
+		{name: "invalid key", p: responseEncryption{Jwk: json.RawMessage(`{"kty":"EC"}`), Alg: "ECDH-ES", Enc: "A256GCM"}, wantErr: true},
+		{name: "private key", p: responseEncryption{Jwk: marshalKey(t, ecKey), Alg: "ECDH-ES", Enc: "A256GCM"}, wantErr: true},
+		{name: "key type of another alg", p: responseEncryption{Jwk: rsaPublic, Alg: "ECDH-ES", Enc: "A256GCM"}, wantErr: true},
+		{name: "ed25519 key", p: responseEncryption{Jwk: marshalKey(t, edPub), Alg: "ECDH-ES", Enc: "A256GCM"}, wantErr: true},
+		{name: "unsupported alg", p: responseEncryption{Jwk: ecPublic, Alg: "dir", Enc: "A256GCM"}, wantErr: true},
+		{name: "unsupported enc", p: responseEncryption{Jwk: ecPublic, Alg: "ECDH-ES", Enc: "A192GCM"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.p.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			s, err := encryptResponse(map[string]any{"credential": "vc"}, &tt.p)
+			if err != nil {
+				t.Fatalf("encryptResponse() error = %v", err)
+			}
+
+			var private any = ecKey
+			switch {
+			case tt.p.Alg == "RSA-OAEP-256":
+				private = rsaKey
+			case bytes.Equal(tt.p.Jwk, xPublic):
+				private = xKey
+			}
+			b, err := jwe.Decrypt([]byte(s), jwe.WithKey(jwa.KeyEncryptionAlgorithm(tt.p.Alg), private))
+			if err != nil {
+				t.Fatalf("response cannot be decrypted: %v", err)
+			}
+			if string(b) != `{"credential":"vc"}` {
+				t.Errorf("decrypted response = %s", b)
+			}
+		})
+	}
+}
+
+func marshalKey(t *testing.T, raw any) json.RawMessage {
+	t.Helper()
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+package issuance
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+)
+
+// ExpireOffers removes expired records and offers stored by reference every STORAGE_EXPIRY_INTERVAL
+// and announces the offers that were never redeemed.
+func ExpireOffers(ctx context.Context, conf config.Config, svc Services) {
+	interval := time.NewTicker(conf.Storage.ExpiryInterval)
+	defer interval.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-interval.C:
+			svc.Offers.Expire(now)
+
+			expired, err := svc.Storage.Expire(now)
+			if err != nil {
+				slog.Error("expired credentials could not be removed", "error", err)
+				continue
+			}
+
+			for _, record := range expired {
+				if !unredeemed(record.Status) {
+					continue
+				}
+
+				logger := logging.Request(record.TenantId, record.RequestId).With(logging.KeyConfigurationId, record.ConfigurationId)
+				logger.Info("offer expired")
+				auditStep(svc.Trail, logger, audit.Entry{
+					Step:            audit.StepOfferExpired,
+					TenantId:        record.TenantId,
+					RequestId:       record.RequestId,
+					ConfigurationId: record.ConfigurationId,
+				})
+				emit(ctx, svc.Publisher, logger, events.Event{
+					Type:            events.TypeOfferExpired,
+					TenantId:        record.TenantId,
+					RequestId:       record.RequestId,
+					ConfigurationId: record.ConfigurationId,
+				})
+			}
+		}
+	}
+}
+
+func emit(ctx context.Context, publisher *events.Publisher, logger *slog.Logger, e events.Event) {
+	if err := publisher.Publish(ctx, e); err != nil {
+		logger.Error("event could not be published", "type", e.Type, "error", err)
+	}
+}
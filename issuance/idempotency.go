@@ -0,0 +1,125 @@
+package issuance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+)
+
+var (
+	ErrRequestConflict   = errors.New("request id was already used with a different payload")
+	ErrRequestInProgress = errors.New("request with this id is still being processed")
+)
+
+type replayEntry struct {
+	fingerprint string
+	reply       *messaging.IssuanceReply
+	expires     time.Time
+}
+
+// replayCache remembers the replies of successful offer requests per tenant and request id, so that
+// retries get the original offer instead of a second one. Failed requests are forgotten and may be retried.
+type replayCache struct {
+	window    time.Duration
+	mu        sync.Mutex
+	entries   map[string]*replayEntry
+	lastSweep time.Time
+}
+
+// newReplayCache returns nil for a zero window, which disables de-duplication.
+func newReplayCache(window time.Duration) *replayCache {
+	if window <= 0 {
+		return nil
+	}
+	return &replayCache{window: window, entries: make(map[string]*replayEntry)}
+}
+
+func requestFingerprint(req messaging.IssuanceRequest) string {
+	b, _ := json.Marshal(struct {
+		Identifier string `json:"identifier"`
+		Payload    any    `json:"payload"`
+	}{req.Identifier, req.Payload})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// reserve returns the reply to replay for a known request. Otherwise the request is reserved and
+// must be completed with complete.
+func (c *replayCache) reserve(req messaging.IssuanceRequest) (*messaging.IssuanceReply, bool, error) {
+	if c == nil || req.RequestId == "" {
+		return nil, false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	key := req.TenantId + "/" + req.RequestId
+	fingerprint := requestFingerprint(req)
+
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		if e.fingerprint != fingerprint {
+			return nil, false, ErrRequestConflict
+		}
+		if e.reply == nil {
+			return nil, false, ErrRequestInProgress
+		}
+		return e.reply, false, nil
+	}
+
+	c.entries[key] = &replayEntry{fingerprint: fingerprint, expires: now.Add(c.window)}
+	return nil, true, nil
+}
+
+// complete keeps a successful reply for the rest of the window and releases failed requests.
+func (c *replayCache) complete(req messaging.IssuanceRequest, reply messaging.IssuanceReply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := req.TenantId + "/" + req.RequestId
+	if reply.Error != nil {
+		delete(c.entries, key)
+		return
+	}
+
+	if e, ok := c.entries[key]; ok {
+		e.reply = &reply
+	}
+}
+
+func (c *replayCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.window {
+		return
+	}
+	c.lastSweep = now
+
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func idempotencyError(err error) *common.Error {
+	if errors.Is(err, ErrRequestInProgress) {
+		return &common.Error{
+			Id:     "request-in-progress",
+			Status: 409,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "request-id-conflict",
+		Status: 409,
+		Msg:    err.Error(),
+	}
+}
@@ -0,0 +1,139 @@
+package issuance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+)
+
+func issuanceRequest(tenantId string, requestId string, name string) messaging.IssuanceRequest {
+	req := messaging.IssuanceRequest{Identifier: "DeveloperCredential", Payload: map[string]interface{}{"name": name}}
+	req.TenantId = tenantId
+	req.RequestId = requestId
+	return req
+}
+
+func TestReplayCache(t *testing.T) {
+	ok := messaging.IssuanceReply{}
+	failed := messaging.IssuanceReply{Reply: common.Reply{Error: &common.Error{Id: "credential-req-error"}}}
+
+	// steps reserve the request and complete it with the reply unless it is nil
+	type step struct {
+		req          messaging.IssuanceRequest
+		complete     *messaging.IssuanceReply
+		wait         bool
+		wantReplayed bool
+		wantReserved bool
+		wantErr      error
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "first request is reserved",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), wantReserved: true},
+			},
+		},
+		{
+			name: "retry after success is replayed",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), complete: &ok, wantReserved: true},
+				{req: issuanceRequest("t1", "r1", "a"), wantReplayed: true},
+			},
+		},
+		{
+			name: "retry while in progress",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), wantReserved: true},
+				{req: issuanceRequest("t1", "r1", "a"), wantErr: ErrRequestInProgress},
+			},
+		},
+		{
+			name: "same id with another payload",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), complete: &ok, wantReserved: true},
+				{req: issuanceRequest("t1", "r1", "b"), wantErr: ErrRequestConflict},
+			},
+		},
+		{
+			name: "failed request may be retried",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), complete: &failed, wantReserved: true},
+				{req: issuanceRequest("t1", "r1", "a"), wantReserved: true},
+			},
+		},
+		{
+			name: "request ids are per tenant",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), complete: &ok, wantReserved: true},
+				{req: issuanceRequest("t2", "r1", "b"), wantReserved: true},
+			},
+		},
+		{
+			name: "window elapsed",
+			steps: []step{
+				{req: issuanceRequest("t1", "r1", "a"), complete: &ok, wantReserved: true, wait: true},
+				{req: issuanceRequest("t1", "r1", "b"), wantReserved: true},
+			},
+		},
+		{
+			name: "requests without id are not de-duplicated",
+			steps: []step{
+				{req: issuanceRequest("t1", "", "a"), wantReserved: false},
+				{req: issuanceRequest("t1", "", "a"), wantReserved: false},
+			},
+		},
+	}
+
+	const window = 20 * time.Millisecond
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newReplayCache(window)
+
+			for i, s := range tt.steps {
+				replayed, reserved, err := c.reserve(s.req)
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: reserve() error = %v, want %v", i, err, s.wantErr)
+				}
+				if (replayed != nil) != s.wantReplayed || reserved != s.wantReserved {
+					t.Fatalf("step %d: reserve() replayed %v, reserved %v, want %v, %v", i, replayed != nil, reserved, s.wantReplayed, s.wantReserved)
+				}
+				if s.complete != nil {
+					c.complete(s.req, *s.complete)
+				}
+				if s.wait {
+					time.Sleep(window + 5*time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayCacheDisabled(t *testing.T) {
+	c := newReplayCache(0)
+
+	replayed, reserved, err := c.reserve(issuanceRequest("t1", "r1", "a"))
+	if replayed != nil || reserved || err != nil {
+		t.Errorf("reserve() on disabled cache = %v, %v, %v", replayed, reserved, err)
+	}
+}
+
+func TestReplayCacheSweep(t *testing.T) {
+	const window = 10 * time.Millisecond
+	c := newReplayCache(window)
+
+	c.reserve(issuanceRequest("t1", "r1", "a"))
+	time.Sleep(window + 5*time.Millisecond)
+	c.reserve(issuanceRequest("t1", "r2", "a"))
+
+	if len(c.entries) != 1 {
+		t.Errorf("cache holds %d entries after the window, want 1", len(c.entries))
+	}
+}
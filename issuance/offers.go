@@ -0,0 +1,442 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/offer"
+	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+)
+
+// Offer management operations.
+const (
+	OfferOpStatus     = "status"
+	OfferOpCancel     = "cancel"
+	OfferOpRegenerate = "regenerate"
+	OfferOpList       = "list"
+)
+
+// States of an offer as reported by the status operation.
+const (
+	OfferCreated   = "created"
+	OfferRedeemed  = "redeemed"
+	OfferExpired   = "expired"
+	OfferCancelled = "cancelled"
+)
+
+var ErrOfferClosed = errors.New("offer can no longer be picked up")
+
+// offerState maps the status of a record to the state of its offer. Rejected credentials count as
+// cancelled, everything after pickup as redeemed.
+func offerState(status string) string {
+	switch status {
+	case StatusPrepared, StatusPendingApproval:
+		return OfferCreated
+	case StatusExpired:
+		return OfferExpired
+	case StatusCancelled, StatusRejected:
+		return OfferCancelled
+	default:
+		return OfferRedeemed
+	}
+}
+
+// OfferRequest selects the offer by Code or, without a code, by the tenant and request id of the
+// envelope. A code must belong to the tenant of the envelope. List returns all offers of the tenant,
+// optionally only those with the record Status.
+type OfferRequest struct {
+	common.Request
+	Operation string `json:"operation"`
+	Code      string `json:"code,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// OfferStatus describes an offer without its code or claims.
+type OfferStatus struct {
+	TenantId        string    `json:"tenant_id"`
+	RequestId       string    `json:"request_id"`
+	ConfigurationId string    `json:"configuration_id"`
+	State           string    `json:"state"`
+	Status          string    `json:"status"`
+	NotificationId  string    `json:"notification_id,omitempty"`
+	Created         time.Time `json:"created"`
+	Updated         time.Time `json:"updated"`
+	// set if the offer was sent by email
+	Delivery *DeliveryStatus `json:"delivery,omitempty"`
+}
+
+// DeliveryStatus is the state of an offer sent by email: pending, sent or failed.
+type DeliveryStatus struct {
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// OfferReply carries the status of the offer after the operation, regenerate adds the new offer.
+type OfferReply struct {
+	common.Reply
+	Offer           *OfferStatus                `json:"offer,omitempty"`
+	CredentialOffer *credential.CredentialOffer `json:"credential_offer,omitempty"`
+	Offers          []*OfferStatus              `json:"offers,omitempty"`
+}
+
+func offerStatus(record *CredentialRecord) *OfferStatus {
+	var d *DeliveryStatus
+	if record.Delivery != "" {
+		d = &DeliveryStatus{
+			Status:  record.Delivery,
+			Error:   record.DeliveryError,
+			Updated: record.DeliveryUpdated,
+		}
+	}
+
+	return &OfferStatus{
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		State:           offerState(record.Status),
+		Status:          record.Status,
+		NotificationId:  record.NotificationId,
+		Created:         record.Created,
+		Updated:         record.Updated,
+		Delivery:        d,
+	}
+}
+
+// findOffer returns the record of the offer selected by the request.
+func findOffer(svc Services, req OfferRequest) (*CredentialRecord, error) {
+	if req.TenantId == "" {
+		return nil, errors.New("tenant_id is required")
+	}
+
+	if req.Code != "" {
+		record, err := svc.Storage.GetCredential(req.Code)
+		if err != nil {
+			return nil, err
+		}
+		// a code of another tenant is not disclosed
+		if record.TenantId != req.TenantId {
+			return nil, ErrNotFound
+		}
+		return record, nil
+	}
+
+	if req.RequestId == "" {
+		return nil, errors.New("request_id or code is required")
+	}
+	return svc.Storage.GetCredentialByRequest(req.TenantId, req.RequestId)
+}
+
+func listOffers(svc Services, req OfferRequest) ([]*OfferStatus, error) {
+	if req.TenantId == "" {
+		return nil, errors.New("tenant_id is required")
+	}
+
+	records, err := svc.Storage.ListCredentials(req.TenantId, req.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := make([]*OfferStatus, 0, len(records))
+	for _, record := range records {
+		offers = append(offers, offerStatus(record))
+	}
+	return offers, nil
+}
+
+// cancelOffer withdraws an offer that was not picked up yet, .issue refuses its code afterwards.
+func cancelOffer(ctx context.Context, svc Services, record *CredentialRecord, reason string) error {
+	if !unredeemed(record.Status) {
+		return fmt.Errorf("%w, it is %s", ErrOfferClosed, record.Status)
+	}
+
+	// an issue or approval in the meantime wins, the cancellation must not overwrite it
+	record.Status = StatusCancelled
+	if err := svc.Storage.TransitionCredential(record, StatusPrepared, StatusPendingApproval); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return fmt.Errorf("%w: %w", ErrOfferClosed, err)
+		}
+		return err
+	}
+
+	svc.Offers.Remove(record.Offer)
+
+	logger := logging.Request(record.TenantId, record.RequestId).With(logging.KeyConfigurationId, record.ConfigurationId)
+	logger.Info("offer cancelled", "reason", reason)
+	auditStep(svc.Trail, logger, audit.Entry{
+		Step:            audit.StepOfferCancelled,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		Detail:          reason,
+	})
+	emit(ctx, svc.Publisher, logger, events.Event{
+		Type:            events.TypeOfferCancelled,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		Reason:          reason,
+	})
+	return nil
+}
+
+// regenerateOffer obtains a new pre-authorized code for the prepared credential, the previous code
+// stops working. Expired offers lost their credential and must be requested again.
+func regenerateOffer(ctx context.Context, svc Services, authclient *cloudeventprovider.CloudEventProviderClient, record *CredentialRecord, groupId string) (*credential.CredentialOffer, *common.Error) {
+	if !unredeemed(record.Status) {
+		return nil, offerError(fmt.Errorf("%w, it is %s", ErrOfferClosed, record.Status))
+	}
+
+	resp, offerErr := requestOffer(ctx, authclient, record.TenantId, record.RequestId, groupId, record.ConfigurationId)
+	if offerErr != nil {
+		return nil, offerErr
+	}
+
+	credentialOffer, err := svc.Offers.Reference(resp.CredentialOffer)
+	if err != nil {
+		return nil, offerReferenceError(err)
+	}
+
+	previous := record.Offer
+	if err := replaceCode(svc.Storage, record, resp.Code, credentialOffer.CredentialOffer); err != nil {
+		svc.Offers.Remove(credentialOffer.CredentialOffer)
+		return nil, offerError(err)
+	}
+	svc.Offers.Remove(previous)
+
+	logger := logging.Request(record.TenantId, record.RequestId).With(logging.KeyConfigurationId, record.ConfigurationId)
+	logger.Info("offer regenerated")
+	auditStep(svc.Trail, logger, audit.Entry{
+		Step:            audit.StepOfferRegenerated,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+	})
+	emit(ctx, svc.Publisher, logger, events.Event{
+		Type:            events.TypeOfferCreated,
+		TenantId:        record.TenantId,
+		RequestId:       record.RequestId,
+		ConfigurationId: record.ConfigurationId,
+		Reason:          "regenerated",
+	})
+	return &credentialOffer, nil
+}
+
+// replaceCode moves the offer to the new code, unless it was redeemed or closed since it was read.
+func replaceCode(storage IssuanceStorage, record *CredentialRecord, code string, offer string) error {
+	if err := storage.ReplaceCode(record.Code, code, offer, StatusPrepared, StatusPendingApproval); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return fmt.Errorf("%w: %w", ErrOfferClosed, err)
+		}
+		return err
+	}
+
+	record.Code, record.Offer = code, offer
+	return nil
+}
+
+// manageOffer runs the operation of the request and builds its reply.
+func manageOffer(ctx context.Context, svc Services, authclient *cloudeventprovider.CloudEventProviderClient, req OfferRequest) OfferReply {
+	reply := OfferReply{Reply: common.Reply{
+		TenantId:  req.TenantId,
+		RequestId: req.RequestId,
+		GroupId:   req.GroupId,
+	}}
+
+	if req.Operation == OfferOpList {
+		offers, err := listOffers(svc, req)
+		if err != nil {
+			reply.Error = offerError(err)
+		}
+		reply.Offers = offers
+		return reply
+	}
+
+	record, err := findOffer(svc, req)
+	if err != nil {
+		reply.Error = offerError(err)
+		return reply
+	}
+	reply.RequestId = record.RequestId
+
+	switch req.Operation {
+	case OfferOpStatus, "":
+	case OfferOpCancel:
+		err = cancelOffer(ctx, svc, record, req.Reason)
+	case OfferOpRegenerate:
+		credentialOffer, offerErr := regenerateOffer(ctx, svc, authclient, record, req.GroupId)
+		if offerErr != nil {
+			reply.Error = offerErr
+		} else {
+			reply.CredentialOffer = credentialOffer
+		}
+	default:
+		err = fmt.Errorf("operation must be %s, %s, %s or %s, got %q", OfferOpStatus, OfferOpCancel, OfferOpRegenerate, OfferOpList, req.Operation)
+	}
+	if err != nil {
+		reply.Error = offerError(err)
+	}
+
+	reply.Offer = offerStatus(record)
+	return reply
+}
+
+func offerError(err error) *common.Error {
+	if errors.Is(err, ErrNotFound) {
+		return &common.Error{
+			Id:     "offer-not-found",
+			Status: 404,
+			Msg:    err.Error(),
+		}
+	}
+
+	if errors.Is(err, ErrOfferClosed) {
+		return &common.Error{
+			Id:     "offer-closed",
+			Status: 409,
+			Msg:    err.Error(),
+		}
+	}
+
+	return &common.Error{
+		Id:     "invalid-offer-request",
+		Status: 400,
+		Msg:    err.Error(),
+	}
+}
+
+// NewOfferingClient connects to the issuer service for regenerated offers.
+func NewOfferingClient(conf config.Config) (*cloudeventprovider.CloudEventProviderClient, error) {
+	return cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+		cloudeventprovider.ConnectionTypeReq,
+		issumsg.TopicOffering,
+	)
+}
+
+// Offers answers offer management requests on OFFERS_TOPIC.
+func Offers(ctx context.Context, conf config.Config, svc Services, authclient *cloudeventprovider.CloudEventProviderClient) {
+	if conf.Offers.Topic == "" {
+		return
+	}
+
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+		cloudeventprovider.ConnectionTypeRep,
+		conf.Offers.Topic,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("serving offer management", "subject", conf.Offers.Topic)
+	serve(ctx, client, conf.Nats.TimeoutInSec, offerHandler(svc, authclient))
+}
+
+func offerHandler(svc Services, authclient *cloudeventprovider.CloudEventProviderClient) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req OfferRequest
+		if err := json.Unmarshal(event.DataEncoded, &req); err != nil {
+			slog.Error("invalid offer request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		reply := manageOffer(ctx, svc, authclient, req)
+		if reply.Error != nil {
+			logging.Request(req.TenantId, req.RequestId).Warn("offer operation failed", "operation", req.Operation, "error", reply.Error.Msg)
+		}
+
+		return replyEvent(reply)
+	}
+}
+
+// OfferHandler serves GET /offers?tenant_id=&request_id= or &code= with the status of an offer,
+// GET /offers?tenant_id=&status= listing the offers of a tenant and
+// POST /offers/{tenant}/{request}/{operation} to cancel or regenerate one, optionally with {"reason"}.
+func OfferHandler(svc Services, authclient *cloudeventprovider.CloudEventProviderClient) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /offers", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		req := OfferRequest{Operation: OfferOpStatus, Code: q.Get("code"), Status: q.Get("status")}
+		req.TenantId = q.Get("tenant_id")
+		req.RequestId = q.Get("request_id")
+		if req.Code == "" && req.RequestId == "" {
+			req.Operation = OfferOpList
+		}
+
+		writeOfferReply(w, manageOffer(r.Context(), svc, authclient, req))
+	})
+
+	mux.HandleFunc("POST /offers/{tenant}/{request}/{operation}", func(w http.ResponseWriter, r *http.Request) {
+		req := OfferRequest{Operation: r.PathValue("operation")}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		req.Operation = r.PathValue("operation")
+		req.TenantId = r.PathValue("tenant")
+		req.RequestId = r.PathValue("request")
+		req.Code = ""
+
+		if req.Operation != OfferOpCancel && req.Operation != OfferOpRegenerate {
+			http.NotFound(w, r)
+			return
+		}
+
+		writeOfferReply(w, manageOffer(r.Context(), svc, authclient, req))
+	})
+
+	return mux
+}
+
+// QRHandler serves GET /qr?tenant_id=&request_id= with the QR code of the offer of a request that can
+// still be picked up, by value or by reference, as format=png or svg in size pixels.
+func QRHandler(svc Services) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		req := OfferRequest{}
+		req.TenantId = q.Get("tenant_id")
+		req.RequestId = q.Get("request_id")
+		if req.TenantId == "" || req.RequestId == "" {
+			http.Error(w, "tenant_id and request_id are required", http.StatusBadRequest)
+			return
+		}
+
+		record, err := findOffer(svc, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if !unredeemed(record.Status) || record.Offer == "" {
+			http.Error(w, fmt.Sprintf("%s, it is %s", ErrOfferClosed, record.Status), http.StatusConflict)
+			return
+		}
+
+		offer.WriteQR(w, r, record.Offer)
+	})
+}
+
+func writeOfferReply(w http.ResponseWriter, reply OfferReply) {
+	if reply.Error != nil {
+		writeJson(w, reply.Error.Status, reply)
+		return
+	}
+	writeJson(w, http.StatusOK, reply)
+}
@@ -0,0 +1,159 @@
+package issuance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/offer"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+)
+
+func TestStaleStatusChange(t *testing.T) {
+	issue := func(svc Services, record *CredentialRecord) error {
+		return issued(svc.Storage, record, "n-"+record.Status)
+	}
+	cancel := func(svc Services, record *CredentialRecord) error {
+		return cancelOffer(context.Background(), svc, record, "test")
+	}
+	regenerate := func(svc Services, record *CredentialRecord) error {
+		return replaceCode(svc.Storage, record, "new-"+record.Code, "")
+	}
+
+	// both changes start from the record as read before either of them, the second must not win
+	tests := []struct {
+		name       string
+		stored     string
+		first      func(Services, *CredentialRecord) error
+		second     func(Services, *CredentialRecord) error
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "issue after cancel", stored: StatusPrepared, first: cancel, second: issue, wantErr: ErrInvalidTransition, wantStatus: StatusCancelled},
+		{name: "cancel after issue", stored: StatusPrepared, first: issue, second: cancel, wantErr: ErrOfferClosed, wantStatus: StatusIssued},
+		{name: "second issue", stored: StatusPrepared, first: issue, second: issue, wantErr: ErrInvalidTransition, wantStatus: StatusIssued},
+		{name: "cancel pending", stored: StatusPendingApproval, first: cancel, second: cancel, wantErr: ErrOfferClosed, wantStatus: StatusCancelled},
+		{name: "issue pending", stored: StatusPendingApproval, first: func(Services, *CredentialRecord) error { return nil }, second: issue, wantErr: ErrInvalidTransition, wantStatus: StatusPendingApproval},
+		{name: "regenerate after issue", stored: StatusPrepared, first: issue, second: regenerate, wantErr: ErrOfferClosed, wantStatus: StatusIssued},
+		{name: "regenerate after cancel", stored: StatusPrepared, first: cancel, second: regenerate, wantErr: ErrOfferClosed, wantStatus: StatusCancelled},
+		{name: "issue after regenerate", stored: StatusPrepared, first: regenerate, second: issue, wantErr: ErrNotFound, wantStatus: StatusPrepared},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewDummyStorage(0)
+			if err := storage.AddCredential(&CredentialRecord{Code: "code", TenantId: "t", RequestId: "r", Status: tt.stored}); err != nil {
+				t.Fatal(err)
+			}
+			svc := Services{Storage: storage}
+
+			first, _ := storage.GetCredential("code")
+			second, _ := storage.GetCredential("code")
+
+			if err := tt.first(svc, first); err != nil {
+				t.Fatalf("first change failed: %v", err)
+			}
+			if err := tt.second(svc, second); !errors.Is(err, tt.wantErr) {
+				t.Errorf("second change error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := storage.GetCredentialByRequest("t", "r")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDecideAfterCancel(t *testing.T) {
+	storage := NewDummyStorage(0)
+	if err := storage.AddCredential(&CredentialRecord{Code: "code", TenantId: "t", RequestId: "r", Status: StatusPendingApproval}); err != nil {
+		t.Fatal(err)
+	}
+	svc := Services{Storage: storage}
+
+	record, _ := storage.GetCredential("code")
+	if err := cancelOffer(context.Background(), svc, record, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := ApprovalRequest{Decision: DecisionApprove, Approver: "alice"}
+	req.TenantId = "t"
+	req.RequestId = "r"
+	if _, err := decide(svc, req); !errors.Is(err, ErrNotPending) {
+		t.Errorf("decide() error = %v, want %v", err, ErrNotPending)
+	}
+}
+
+func TestQRHandler(t *testing.T) {
+	storage := NewDummyStorage(0)
+	for _, r := range []*CredentialRecord{
+		{Code: "c1", TenantId: "t", RequestId: "prepared", Status: StatusPrepared, Offer: `openid-credential-offer://?credential_offer={"credential_issuer":"x"}`},
+		{Code: "c2", TenantId: "t", RequestId: "cancelled", Status: StatusCancelled, Offer: `openid-credential-offer://?credential_offer={"credential_issuer":"x"}`},
+	} {
+		if err := storage.AddCredential(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := QRHandler(Services{Storage: storage})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "offer by value", query: "tenant_id=t&request_id=prepared&format=svg", wantStatus: http.StatusOK},
+		{name: "closed offer", query: "tenant_id=t&request_id=cancelled", wantStatus: http.StatusConflict},
+		{name: "other tenant", query: "tenant_id=u&request_id=prepared", wantStatus: http.StatusNotFound},
+		{name: "unknown request", query: "tenant_id=t&request_id=unknown", wantStatus: http.StatusNotFound},
+		{name: "no request", query: "tenant_id=t", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/qr?"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK && w.Header().Get("Content-Type") != "image/svg+xml" {
+				t.Errorf("Content-Type = %s", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestCancelDropsOfferReference(t *testing.T) {
+	conf := config.Default()
+	conf.Offers.ByReference = true
+	conf.Offers.BaseUrl = "https://issuer.example.com"
+	offers := offer.New(conf)
+
+	referenced, err := offers.Reference(credential.CredentialOffer{CredentialOffer: `openid-credential-offer://?credential_offer={"credential_issuer":"x"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := offers.Lookup(referenced.CredentialOffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := NewDummyStorage(0)
+	if err := storage.AddCredential(&CredentialRecord{Code: "code", TenantId: "t", RequestId: "r", Offer: referenced.CredentialOffer}); err != nil {
+		t.Fatal(err)
+	}
+
+	record, _ := storage.GetCredential("code")
+	if err := cancelOffer(context.Background(), Services{Storage: storage, Offers: offers}, record, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := offers.Get(id); !errors.Is(err, offer.ErrNotFound) {
+		t.Errorf("offer of cancelled code still resolves: %v", err)
+	}
+}
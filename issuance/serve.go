@@ -0,0 +1,208 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+var ErrShuttingDown = errors.New("issuance module is shutting down")
+
+type replyFunc func(ctx context.Context, event event.Event) (*event.Event, error)
+
+// tracker counts the requests in flight on all reply handlers so that a shutdown can wait for them.
+type tracker struct {
+	mu      sync.Mutex
+	closed  bool
+	active  sync.WaitGroup
+	drained chan struct{}
+	work    context.Context
+	cancel  context.CancelFunc
+}
+
+var inflight = newTracker()
+
+func newTracker() *tracker {
+	work, cancel := context.WithCancel(context.Background())
+	return &tracker{
+		drained: make(chan struct{}),
+		work:    work,
+		cancel:  cancel,
+	}
+}
+
+func (t *tracker) enter() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.active.Add(1)
+	return true
+}
+
+func (t *tracker) leave() {
+	t.active.Done()
+}
+
+type trackerKey struct{}
+
+// Tracked returns a context for the handlers of one instance of the module together with the drain of
+// its requests, which then replaces Drain. It lets several instances run in one process, e.g. in tests.
+func Tracked(ctx context.Context) (context.Context, func(context.Context) error) {
+	t := newTracker()
+	return context.WithValue(ctx, trackerKey{}, t), t.drain
+}
+
+// trackerOf returns the tracker of a context from Tracked, the one of Drain otherwise.
+func trackerOf(ctx context.Context) *tracker {
+	if t, ok := ctx.Value(trackerKey{}).(*tracker); ok {
+		return t
+	}
+	return inflight
+}
+
+// Drain refuses new requests and waits until the requests in flight are answered or ctx expires.
+// On expiry the contexts of the remaining requests are cancelled. Reply clients are closed afterwards.
+func Drain(ctx context.Context) error {
+	return inflight.drain(ctx)
+}
+
+func (t *tracker) drain(ctx context.Context) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+
+	defer close(t.drained)
+
+	done := make(chan struct{})
+	go func() {
+		t.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.cancel()
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		return ctx.Err()
+	}
+}
+
+// guard binds each request to the drain deadline instead of the subscription context, which ends with the
+// client. If timeout is set the request additionally gets the deadline the requester is waiting for.
+func (t *tracker) guard(timeout time.Duration, fn replyFunc) replyFunc {
+	return func(ctx context.Context, ev event.Event) (*event.Event, error) {
+		if !t.enter() {
+			return refuse(ev)
+		}
+		defer t.leave()
+
+		ctx = t.work
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		return fn(ctx, ev)
+	}
+}
+
+func refuse(ev event.Event) (*event.Event, error) {
+	var req common.Request
+	_ = json.Unmarshal(ev.DataEncoded, &req)
+
+	return replyEvent(common.Reply{
+		TenantId:  req.TenantId,
+		RequestId: req.RequestId,
+		GroupId:   req.GroupId,
+		Error: &common.Error{
+			Id:     "shutting-down",
+			Status: 503,
+			Msg:    ErrShuttingDown.Error(),
+		},
+	})
+}
+
+func replyEvent(reply any) (*event.Event, error) {
+	b, err := json.Marshal(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	ev, err := cloudeventprovider.NewEvent("test-issuer", "dummycontentsigner", b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ev, nil
+}
+
+// serve answers requests on client until ctx is done. The client is closed once Drain has finished,
+// so that replies of in-flight requests can still be sent.
+func serve(ctx context.Context, client *cloudeventprovider.CloudEventProviderClient, timeout time.Duration, fn replyFunc) {
+	t := trackerOf(ctx)
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for ctx.Err() == nil {
+			if err := client.ReplyCtx(context.Background(), t.guard(timeout, fn)); err != nil {
+				slog.Error("reply handler failed", "error", err)
+			}
+		}
+	}()
+
+	<-ctx.Done()
+	<-t.drained
+
+	if err := client.Close(); err != nil {
+		slog.Error("reply client could not be closed", "error", err)
+	}
+
+	<-stopped
+}
+
+// serveSubjects subscribes to every configured subject with the given suffix and serves each with the
+// handler built for its configurations. It returns when all subscriptions are closed.
+func serveSubjects(ctx context.Context, conf config.Config, suffix string, handler func(s subjectHandlers) replyFunc) {
+	var wg sync.WaitGroup
+
+	for _, s := range subjects(conf.SubjectPrefix) {
+		client, err := cloudeventprovider.New(
+			cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+			cloudeventprovider.ConnectionTypeRep,
+			s.subject+suffix,
+		)
+		if err != nil {
+			panic(err)
+		}
+
+		slog.Info("serving credential configurations", "subject", s.subject+suffix, "configurations", s.ids())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(ctx, client, conf.Nats.TimeoutInSec, handler(s))
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,26 @@
+package issuance
+
+import (
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/claims"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/delivery"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/limits"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/mapping"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/offer"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/policy"
+)
+
+// Services are the collaborators shared by the handlers. Storage is required, every other member
+// may be nil to disable it.
+type Services struct {
+	Storage   IssuanceStorage
+	Publisher *events.Publisher
+	Trail     *audit.Trail
+	Limiter   *limits.Limiter
+	Claims    *claims.Resolver
+	Mapper    *mapping.Mapper
+	Policy    *policy.Engine
+	Offers    *offer.Store
+	Mailer    *delivery.Mailer
+}
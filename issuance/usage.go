@@ -0,0 +1,64 @@
+package issuance
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/limits"
+)
+
+type UsageReply struct {
+	common.Reply
+	Usage limits.Usage `json:"usage"`
+}
+
+// LimitsUsage answers requests for the limit usage of the requesting tenant on LIMITS_USAGE_TOPIC.
+func LimitsUsage(ctx context.Context, conf config.Config, limiter *limits.Limiter) {
+	if conf.Limits.UsageTopic == "" {
+		return
+	}
+
+	client, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
+		cloudeventprovider.ConnectionTypeRep,
+		conf.Limits.UsageTopic,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	slog.Info("serving limit usage", "subject", conf.Limits.UsageTopic)
+	serve(ctx, client, conf.Nats.TimeoutInSec, usageHandler(limiter))
+}
+
+func usageHandler(limiter *limits.Limiter) replyFunc {
+	return func(ctx context.Context, event event.Event) (*event.Event, error) {
+		var req common.Request
+		if err := json.Unmarshal(event.DataEncoded, &req); err != nil {
+			slog.Error("invalid usage request", "event_id", event.ID(), "error", err)
+			return nil, err
+		}
+
+		return replyEvent(UsageReply{
+			Reply: common.Reply{
+				TenantId:  req.TenantId,
+				RequestId: req.RequestId,
+				GroupId:   req.GroupId,
+			},
+			Usage: limiter.Usage(req.TenantId),
+		})
+	}
+}
+
+func limitError(err error) *common.Error {
+	return &common.Error{
+		Id:     "rate-limited",
+		Status: 429,
+		Msg:    err.Error(),
+	}
+}
@@ -0,0 +1,46 @@
+package limits
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is a token bucket refilled with rate tokens per second up to burst.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	b := float64(burst)
+	if b < 1 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &bucket{rate: rate, burst: b, tokens: b, last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+func (b *bucket) available(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= 1
+}
+
+func (b *bucket) take() {
+	b.tokens--
+}
+
+// retryAfter is the time until the next token is available.
+func (b *bucket) retryAfter() time.Duration {
+	if b.tokens >= 1 || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,207 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Operations that are limited.
+const (
+	OpRequest = "request"
+	OpIssue   = "issue"
+)
+
+var (
+	ErrRateLimited   = errors.New("rate limit exceeded")
+	ErrQuotaExceeded = errors.New("daily issuance quota exceeded")
+)
+
+// Limiter enforces the token buckets per tenant and per credential configuration and the daily
+// issuance quota per tenant. State is kept in memory per instance. A nil Limiter allows everything.
+type Limiter struct {
+	conf    config.Config
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	day     string
+	issued  map[string]int
+	// quota slots taken by issues in progress
+	reserved map[string]int
+	swept    time.Time
+}
+
+// sweepInterval is how often buckets that refilled completely are dropped. Tenant ids are not checked
+// against the configured tenants, so buckets would otherwise pile up for every id ever seen.
+const sweepInterval = time.Minute
+
+func New(conf config.Config) *Limiter {
+	return &Limiter{
+		conf:     conf,
+		buckets:  make(map[bucketKey]*bucket),
+		issued:   make(map[string]int),
+		reserved: make(map[string]int),
+	}
+}
+
+// settings merges the limits of the tenant into the global ones, fields set for the tenant win.
+func (l *Limiter) settings(tenantId string) config.TenantLimitsConfig {
+	s := config.TenantLimitsConfig{
+		Request:    l.conf.Limits.Request,
+		Issue:      l.conf.Limits.Issue,
+		DailyQuota: l.conf.Limits.DailyQuota,
+	}
+
+	for _, t := range l.conf.Tenants {
+		if t.Id != tenantId {
+			continue
+		}
+		s.Request = merge(s.Request, t.Limits.Request)
+		s.Issue = merge(s.Issue, t.Limits.Issue)
+		if t.Limits.DailyQuota != 0 {
+			s.DailyQuota = t.Limits.DailyQuota
+		}
+	}
+	return s
+}
+
+func merge(base config.RateConfig, override config.RateConfig) config.RateConfig {
+	if override.TenantRate != 0 {
+		base.TenantRate = override.TenantRate
+		base.TenantBurst = override.TenantBurst
+	}
+	if override.ConfigurationRate != 0 {
+		base.ConfigurationRate = override.ConfigurationRate
+		base.ConfigurationBurst = override.ConfigurationBurst
+	}
+	return base
+}
+
+// today resets the quota counters at midnight UTC.
+func (l *Limiter) today(now time.Time) string {
+	day := now.UTC().Format(time.DateOnly)
+	if day != l.day {
+		l.day = day
+		clear(l.issued)
+		clear(l.reserved)
+	}
+	return day
+}
+
+// bucketKey names a bucket, the bucket of the tenant has no configuration.
+type bucketKey struct {
+	operation       string
+	tenantId        string
+	configurationId string
+}
+
+func (l *Limiter) bucket(key bucketKey, rate float64, burst int, now time.Time) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	b, ok := l.buckets[key]
+	if !ok || b.rate != rate {
+		b = newBucket(rate, burst, now)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// sweep drops the buckets that are full again, a new bucket starts in the same state.
+func (l *Limiter) sweep(now time.Time) {
+	l.swept = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow takes a token from the buckets of the tenant and of the credential configuration, only if both
+// have one. Both operations are refused once the daily quota of the tenant is used up. An allowed issue
+// reserves a slot of the quota, so that concurrent issues cannot exceed it. The caller ends the
+// reservation with Issued or Release.
+func (l *Limiter) Allow(op string, tenantId string, configurationId string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.today(now)
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	s := l.settings(tenantId)
+	if s.DailyQuota > 0 && l.issued[tenantId]+l.reserved[tenantId] >= s.DailyQuota {
+		return fmt.Errorf("%w: %d credentials issued for %q today", ErrQuotaExceeded, l.issued[tenantId]+l.reserved[tenantId], tenantId)
+	}
+
+	rate := s.Request
+	if op == OpIssue {
+		rate = s.Issue
+	}
+
+	buckets := []*bucket{
+		l.bucket(bucketKey{op, tenantId, ""}, rate.TenantRate, rate.TenantBurst, now),
+		l.bucket(bucketKey{op, tenantId, configurationId}, rate.ConfigurationRate, rate.ConfigurationBurst, now),
+	}
+
+	for _, b := range buckets {
+		if b != nil && !b.available(now) {
+			return fmt.Errorf("%w for %s on %q, retry in %s", ErrRateLimited, op, tenantId, b.retryAfter().Round(time.Millisecond))
+		}
+	}
+	for _, b := range buckets {
+		if b != nil {
+			b.take()
+		}
+	}
+	if op == OpIssue && s.DailyQuota > 0 {
+		l.reserved[tenantId]++
+	}
+	return nil
+}
+
+// Issued counts the credential of an allowed issue against the daily quota of the tenant.
+func (l *Limiter) Issued(tenantId string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.today(time.Now())
+	l.unreserve(tenantId)
+	l.issued[tenantId]++
+}
+
+// Release frees the quota slot of an allowed issue that failed.
+func (l *Limiter) Release(tenantId string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.today(time.Now())
+	l.unreserve(tenantId)
+}
+
+// unreserve drops a reservation, those of the previous day are already gone.
+func (l *Limiter) unreserve(tenantId string) {
+	if l.reserved[tenantId] > 1 {
+		l.reserved[tenantId]--
+	} else {
+		delete(l.reserved, tenantId)
+	}
+}
@@ -0,0 +1,215 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func TestBucket(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		// takes at the offsets from start
+		at   []time.Duration
+		want []bool
+	}{
+		{name: "burst", rate: 1, burst: 3, at: []time.Duration{0, 0, 0, 0}, want: []bool{true, true, true, false}},
+		{name: "burst defaults to rate", rate: 2, at: []time.Duration{0, 0, 0}, want: []bool{true, true, false}},
+		{name: "burst at least one", rate: 0.5, at: []time.Duration{0, 0}, want: []bool{true, false}},
+		{name: "refill", rate: 1, burst: 1, at: []time.Duration{0, 500 * time.Millisecond, time.Second}, want: []bool{true, false, true}},
+		{name: "refill up to burst", rate: 10, burst: 2, at: []time.Duration{0, 0, time.Hour, time.Hour, time.Hour}, want: []bool{true, true, true, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBucket(tt.rate, tt.burst, start)
+			for i, at := range tt.at {
+				got := b.available(start.Add(at))
+				if got {
+					b.take()
+				}
+				if got != tt.want[i] {
+					t.Fatalf("take %d at %s = %v, want %v", i, at, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	b := newBucket(2, 1, now)
+	b.available(now)
+	b.take()
+
+	if got := b.retryAfter(); got != 500*time.Millisecond {
+		t.Errorf("retryAfter() = %s, want 500ms", got)
+	}
+}
+
+func TestLimiterSettings(t *testing.T) {
+	conf := config.Default()
+	conf.Limits.Issue = config.RateConfig{TenantRate: 1, TenantBurst: 1}
+	conf.Limits.DailyQuota = 10
+	conf.Tenants = []config.TenantConfig{
+		{Id: "t1", Limits: config.TenantLimitsConfig{Issue: config.RateConfig{TenantRate: 5, TenantBurst: 5}, DailyQuota: 2}},
+		{Id: "t2"},
+	}
+	l := New(conf)
+
+	tests := []struct {
+		tenantId  string
+		wantRate  float64
+		wantQuota int
+	}{
+		{tenantId: "t1", wantRate: 5, wantQuota: 2},
+		{tenantId: "t2", wantRate: 1, wantQuota: 10},
+		{tenantId: "unknown", wantRate: 1, wantQuota: 10},
+	}
+
+	for _, tt := range tests {
+		s := l.settings(tt.tenantId)
+		if s.Issue.TenantRate != tt.wantRate || s.DailyQuota != tt.wantQuota {
+			t.Errorf("settings(%q) = rate %v, quota %d, want %v, %d", tt.tenantId, s.Issue.TenantRate, s.DailyQuota, tt.wantRate, tt.wantQuota)
+		}
+	}
+}
+
+func TestLimiterQuota(t *testing.T) {
+	// steps: r allows a request, i allows an issue, d counts it as issued, x releases it
+	tests := []struct {
+		name  string
+		steps string
+		want  []error
+	}{
+		{name: "issues up to the quota", steps: "ididi", want: []error{nil, nil, ErrQuotaExceeded}},
+		{name: "requests refused once used up", steps: "ididr", want: []error{nil, nil, ErrQuotaExceeded}},
+		{name: "reserved slots count", steps: "iii", want: []error{nil, nil, ErrQuotaExceeded}},
+		{name: "released slots are free again", steps: "iixi", want: []error{nil, nil, nil}},
+		{name: "requests do not reserve", steps: "rrrii", want: []error{nil, nil, nil, nil, nil}},
+	}
+
+	conf := config.Default()
+	conf.Limits.DailyQuota = 2
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(conf)
+
+			var got []error
+			for _, step := range tt.steps {
+				switch step {
+				case 'r':
+					got = append(got, l.Allow(OpRequest, "t1", "c1"))
+				case 'i':
+					got = append(got, l.Allow(OpIssue, "t1", "c1"))
+				case 'd':
+					l.Issued("t1")
+				case 'x':
+					l.Release("t1")
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d answers, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !errors.Is(got[i], tt.want[i]) {
+					t.Errorf("answer %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterQuotaConcurrent(t *testing.T) {
+	conf := config.Default()
+	conf.Limits.DailyQuota = 5
+	l := New(conf)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Allow(OpIssue, "t1", "c1") == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+				l.Issued("t1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 || l.Usage("t1").Issued != 5 {
+		t.Errorf("allowed %d issues, counted %d, want 5", allowed, l.Usage("t1").Issued)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	conf := config.Default()
+	conf.Limits.Request = config.RateConfig{TenantRate: 1, TenantBurst: 2, ConfigurationRate: 1, ConfigurationBurst: 1}
+	l := New(conf)
+
+	tests := []struct {
+		tenantId, configurationId string
+		want                      error
+	}{
+		{"t1", "c1", nil},
+		{"t1", "c1", ErrRateLimited},
+		{"t1", "c2", nil},
+		{"t1", "c3", ErrRateLimited},
+		{"t2", "c1", nil},
+	}
+
+	for i, tt := range tests {
+		if err := l.Allow(OpRequest, tt.tenantId, tt.configurationId); !errors.Is(err, tt.want) {
+			t.Errorf("request %d for %s/%s = %v, want %v", i, tt.tenantId, tt.configurationId, err, tt.want)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	conf := config.Default()
+	conf.Limits.Request = config.RateConfig{TenantRate: 1, TenantBurst: 2}
+	l := New(conf)
+
+	for i := range 100 {
+		if err := l.Allow(OpRequest, fmt.Sprintf("t%d", i), "c1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	if len(l.buckets) != 100 {
+		t.Fatalf("%d buckets after sweeping used ones, want 100", len(l.buckets))
+	}
+	l.sweep(now.Add(2 * time.Second))
+	if len(l.buckets) != 0 {
+		t.Errorf("%d buckets after sweeping refilled ones, want none", len(l.buckets))
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	if err := l.Allow(OpIssue, "t1", "c1"); err != nil {
+		t.Errorf("Allow() = %v", err)
+	}
+	l.Issued("t1")
+	l.Release("t1")
+}
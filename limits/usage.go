@@ -0,0 +1,82 @@
+package limits
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Usage is the current consumption of the limits of a tenant.
+type Usage struct {
+	TenantId   string        `json:"tenant_id"`
+	Day        string        `json:"day"`
+	Issued     int           `json:"issued"`
+	InProgress int           `json:"in_progress,omitempty"`
+	DailyQuota int           `json:"daily_quota,omitempty"`
+	Buckets    []BucketUsage `json:"buckets"`
+}
+
+// BucketUsage is the state of one token bucket, ConfigurationId is empty for the bucket of the tenant.
+type BucketUsage struct {
+	Operation       string  `json:"operation"`
+	ConfigurationId string  `json:"configuration_id,omitempty"`
+	Tokens          float64 `json:"tokens"`
+	Burst           float64 `json:"burst"`
+	Rate            float64 `json:"rate"`
+}
+
+// Usage returns the usage of the tenant. Buckets show up once they were used, until they are full again.
+func (l *Limiter) Usage(tenantId string) Usage {
+	u := Usage{TenantId: tenantId, Buckets: []BucketUsage{}}
+	if l == nil {
+		return u
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	u.Day = l.today(now)
+	u.Issued = l.issued[tenantId]
+	u.InProgress = l.reserved[tenantId]
+	u.DailyQuota = l.settings(tenantId).DailyQuota
+
+	for key, b := range l.buckets {
+		if key.tenantId != tenantId {
+			continue
+		}
+
+		b.refill(now)
+		u.Buckets = append(u.Buckets, BucketUsage{
+			Operation:       key.operation,
+			ConfigurationId: key.configurationId,
+			Tokens:          b.tokens,
+			Burst:           b.burst,
+			Rate:            b.rate,
+		})
+	}
+
+	sort.Slice(u.Buckets, func(i, j int) bool {
+		a, b := u.Buckets[i], u.Buckets[j]
+		if a.Operation != b.Operation {
+			return a.Operation < b.Operation
+		}
+		return a.ConfigurationId < b.ConfigurationId
+	})
+	return u
+}
+
+// Handler serves GET /usage?tenant_id=.
+func Handler(l *Limiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantId := r.URL.Query().Get("tenant_id")
+		if tenantId == "" {
+			http.Error(w, "tenant_id is required", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(l.Usage(tenantId))
+	})
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const Redacted = "[REDACTED]"
+
+const (
+	KeyRequestId       = "request_id"
+	KeyTenantId        = "tenant_id"
+	KeyConfigurationId = "configuration_id"
+)
+
+// sensitiveKeys are attribute and map keys whose values never reach the log output.
+var sensitiveKeys = map[string]bool{
+	"code":                true,
+	"pre-authorized_code": true,
+	"tx_code":             true,
+	"nonce":               true,
+	"c_nonce":             true,
+	"holder":              true,
+	"proof":               true,
+	"payload":             true,
+	"credential":          true,
+	"credentialsubject":   true,
+	"credential_subject":  true,
+	"recipient":           true,
+	"password":            true,
+}
+
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func New(w io.Writer, level string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}))
+}
+
+// Setup installs the JSON logger as slog default so that packages can log via slog.Default().
+func Setup(level string) *slog.Logger {
+	logger := New(os.Stdout, level)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Request returns a logger annotated with the identifiers of one issuance request.
+func Request(tenantId string, requestId string) *slog.Logger {
+	return slog.Default().With(
+		slog.String(KeyTenantId, tenantId),
+		slog.String(KeyRequestId, requestId),
+	)
+}
+
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// Redact returns a copy of v in which the values of all sensitive keys are masked.
+func Redact(v any) any {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			if IsSensitive(k) {
+				m[k] = Redacted
+				continue
+			}
+			m[k] = Redact(val)
+		}
+		return m
+	case map[string]string:
+		m := make(map[string]string, len(t))
+		for k, val := range t {
+			if IsSensitive(k) {
+				val = Redacted
+			}
+			m[k] = val
+		}
+		return m
+	case []map[string]interface{}:
+		s := make([]interface{}, len(t))
+		for i, val := range t {
+			s[i] = Redact(val)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, val := range t {
+			s[i] = Redact(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		return slog.Any(a.Key, Redact(a.Value.Any()))
+	}
+	return a
+}
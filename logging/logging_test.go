@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{
+			name: "sensitive keys of any case",
+			in:   map[string]interface{}{"Code": "abc", "name": "x", "credentialSubject": map[string]interface{}{"a": 1}},
+			want: map[string]interface{}{"Code": Redacted, "name": "x", "credentialSubject": Redacted},
+		},
+		{
+			name: "nested maps and lists",
+			in:   map[string]interface{}{"offers": []interface{}{map[string]interface{}{"tx_code": "1234", "id": "o1"}}},
+			want: map[string]interface{}{"offers": []interface{}{map[string]interface{}{"tx_code": Redacted, "id": "o1"}}},
+		},
+		{
+			name: "string maps",
+			in:   map[string]string{"password": "secret", "host": "mail"},
+			want: map[string]string{"password": Redacted, "host": "mail"},
+		},
+		{
+			name: "lists of maps",
+			in:   []map[string]interface{}{{"nonce": "n", "kid": "k"}},
+			want: []interface{}{map[string]interface{}{"nonce": Redacted, "kid": "k"}},
+		},
+		{
+			name: "other values",
+			in:   42,
+			want: 42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Redact() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDoesNotChangeInput(t *testing.T) {
+	in := map[string]interface{}{"holder": "did:example:1"}
+	Redact(in)
+	if in["holder"] != "did:example:1" {
+		t.Errorf("input changed to %v", in)
+	}
+}
+
+func TestLoggerRedactsAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []any
+		leaks string
+	}{
+		{name: "sensitive attribute", args: []any{"code", "pre-auth-123"}, leaks: "pre-auth-123"},
+		{name: "payload map", args: []any{"claims", map[string]interface{}{"payload": map[string]interface{}{"birthdate": "1999-12-31"}}}, leaks: "1999-12-31"},
+		{name: "string map", args: []any{"headers", map[string]string{"password": "hunter2"}}, leaks: "hunter2"},
+		{name: "grouped attribute", args: []any{"request", map[string]interface{}{"recipient": "jane@example.org"}}, leaks: "jane@example.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(&buf, "debug").Info("test", tt.args...)
+
+			if strings.Contains(buf.String(), tt.leaks) {
+				t.Errorf("log output contains %q: %s", tt.leaks, buf.String())
+			}
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("log output is no json line: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoggerKeepsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, "info").Warn("test", "error", errors.New("claim birthdate: not a date"))
+
+	if !strings.Contains(buf.String(), "claim birthdate: not a date") {
+		t.Errorf("error missing from log output: %s", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]string{
+		"debug":   "DEBUG",
+		" WARN ":  "WARN",
+		"error":   "ERROR",
+		"":        "INFO",
+		"verbose": "INFO",
+	}
+
+	for in, want := range tests {
+		if got := ParseLevel(in).String(); got != want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -1,33 +1,247 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/audit"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/bulk"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/claims"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/delivery"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/events"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/issuance"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/limits"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/logging"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/mapping"
 	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
-	"github.com/kelseyhightower/envconfig"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/offer"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/policy"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/server"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/signer"
+	_ "github.com/lib/pq"
 )
 
-var conf config.Config
-
 func main() {
-	var wg sync.WaitGroup
-	wg.Add(1)
-	if err := envconfig.Process("", &conf); err != nil {
-		panic(fmt.Sprintf("failed to load config from env: %+v", err))
+	conf, err := config.Load()
+	if err == nil {
+		err = metadata.Validate(conf)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Setup(conf.LogLevel)
+
+	trail, err := audit.Open(conf.Audit.File)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	resolver, err := claims.New(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	mapper, err := mapping.New(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	storage := new(issuance.DummyStorage)
+	policies, err := policy.Load(conf.Policy.Files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	mailer, err := delivery.New(conf, delivery.NewSmtpSender(conf.Mail))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	signerClient, err := signer.New(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	storage := issuance.NewDummyStorage(conf.Storage.TTL)
+	storage.Retention = conf.Storage.Retention
+	publisher := events.New(conf)
+	limiter := limits.New(conf)
+	offerStore := offer.New(conf)
+
+	services := issuance.Services{
+		Storage:   storage,
+		Publisher: publisher,
+		Trail:     trail,
+		Limiter:   limiter,
+		Claims:    resolver,
+		Mapper:    mapper,
+		Policy:    policies,
+		Offers:    offerStore,
+		Mailer:    mailer,
+	}
+
+	offering, err := issuance.NewOfferingClient(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer offering.Close()
+
+	requester := bulk.NewNatsRequester(conf)
+	jobs := bulk.New(conf, requester.Request)
+
+	var wg sync.WaitGroup
+	wg.Add(14)
 
 	//publish metadata
-	go metadata.Publish(conf)
+	go func() {
+		defer wg.Done()
+		metadata.Publish(ctx, conf)
+	}()
 
 	//reply to credential request
-	go issuance.CredentialReply(conf, storage)
+	go func() {
+		defer wg.Done()
+		issuance.CredentialReply(ctx, conf, services, signerClient)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.CredentialRequest(ctx, conf, services)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.CredentialNotification(ctx, conf, services)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.CredentialRevocation(ctx, conf, services)
+	}()
 
-	go issuance.CredentialRequest(conf, storage)
+	go func() {
+		defer wg.Done()
+		issuance.ExpireOffers(ctx, conf, services)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.AuditQuery(ctx, conf, trail)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.LimitsUsage(ctx, conf, limiter)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.Approvals(ctx, conf, services)
+	}()
+
+	go func() {
+		defer wg.Done()
+		issuance.Offers(ctx, conf, services, offering)
+	}()
+
+	go func() {
+		defer wg.Done()
+		jobs.Run(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		mailer.Run(ctx)
+	}()
+
+	mux := http.NewServeMux()
+	offerRefs := offer.Handler(offerStore)
+	mux.Handle("/credential-offer/", offerRefs)
+	mux.Handle("/qr", offerRefs)
+
+	go func() {
+		defer wg.Done()
+		server.Serve(ctx, conf, mux)
+	}()
+
+	// administrative routes, served apart from the wallet facing ones
+	admin := http.NewServeMux()
+	admin.Handle("GET /audit", audit.Handler(trail))
+	admin.Handle("GET /usage", limits.Handler(limiter))
+	approvals := issuance.ApprovalHandler(services)
+	admin.Handle("/approvals", approvals)
+	admin.Handle("/approvals/", approvals)
+	offers := issuance.OfferHandler(services, offering)
+	admin.Handle("/offers", offers)
+	admin.Handle("/offers/", offers)
+	admin.Handle("GET /qr", issuance.QRHandler(services))
+	if jobs != nil {
+		bulkJobs := bulk.Handler(conf, jobs)
+		admin.Handle("/bulk", bulkJobs)
+		admin.Handle("/bulk/", bulkJobs)
+	}
+
+	go func() {
+		defer wg.Done()
+		server.ServeAdmin(ctx, conf, admin)
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("shutdown requested, draining in-flight requests", "timeout", conf.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	if err := issuance.Drain(shutdownCtx); err != nil {
+		slog.Warn("in-flight requests did not finish before the shutdown deadline", "error", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		slog.Warn("clients did not close before the shutdown deadline")
+	}
+
+	if err := requester.Close(); err != nil {
+		slog.Error("bulk requester could not be closed", "error", err)
+	}
+
+	if err := publisher.Close(); err != nil {
+		slog.Error("event publisher could not be closed", "error", err)
+	}
+
+	if err := storage.Flush(); err != nil {
+		slog.Error("storage could not be flushed", "error", err)
+	}
+
+	if err := trail.Close(); err != nil {
+		slog.Error("audit trail could not be closed", "error", err)
+	}
 
-	wg.Wait()
+	slog.Info("shutdown complete")
 }
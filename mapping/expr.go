@@ -0,0 +1,136 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// env is the CEL environment of expressions: claims holds the claims as mapped so far, now the current
+// time. age(date, time) returns the full years from a date in one of the date layouts to the time,
+// age(date) is short for age(date, now).
+var env = func() *cel.Env {
+	e, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+		cel.Macros(cel.GlobalMacro("age", 1, func(eh cel.MacroExprFactory, target ast.Expr, args []ast.Expr) (ast.Expr, *common.Error) {
+			return eh.NewCall("age", args[0], eh.NewIdent("now")), nil
+		})),
+		cel.Function("age",
+			cel.Overload("age_string_timestamp", []*cel.Type{cel.StringType, cel.TimestampType}, cel.IntType,
+				cel.BinaryBinding(func(date ref.Val, now ref.Val) ref.Val {
+					t, err := parseDate(fmt.Sprint(date.Value()), "")
+					if err != nil {
+						return types.WrapErr(fmt.Errorf("age: %w: %w", ErrInvalidClaim, err))
+					}
+					return types.Int(age(t, now.(types.Timestamp).Time))
+				}),
+			),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}()
+
+func age(birth time.Time, now time.Time) int {
+	years := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		years--
+	}
+	return years
+}
+
+type exprRule struct {
+	to      string
+	program cel.Program
+	// paths of the claims the expression reads
+	reads []string
+}
+
+func newExprRule(to string, expr string) (rule, error) {
+	checked, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("expression %q: %w", expr, issues.Err())
+	}
+
+	program, err := env.Program(checked)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", expr, err)
+	}
+
+	return exprRule{to: to, program: program, reads: reads(checked.NativeRep().Expr())}, nil
+}
+
+// reads returns the dotted paths of the claims selected in the expression, e.g. address.city for
+// claims.address.city. Presence tests with has() are left out, they do not fail on missing claims.
+func reads(expr ast.Expr) []string {
+	var paths []string
+	ast.PostOrderVisit(expr, ast.NewExprVisitor(func(e ast.Expr) {
+		if e.Kind() != ast.SelectKind || e.AsSelect().IsTestOnly() {
+			return
+		}
+		var fields []string
+		for e.Kind() == ast.SelectKind {
+			fields = append([]string{e.AsSelect().FieldName()}, fields...)
+			e = e.AsSelect().Operand()
+		}
+		if e.Kind() == ast.IdentKind && e.AsIdent() == "claims" {
+			paths = append(paths, strings.Join(fields, "."))
+		}
+	}))
+	return paths
+}
+
+// apply skips the rule if the expression fails while a claim it reads is missing, like the other rules
+// do. Other failures mean the expression does not apply to the claims and fail with ErrInvalidClaim.
+func (r exprRule) apply(claims map[string]interface{}) error {
+	out, _, err := r.program.Eval(map[string]interface{}{
+		"claims": claims,
+		"now":    time.Now(),
+	})
+	if err != nil {
+		for _, path := range r.reads {
+			if _, ok := lookup(claims, path); !ok {
+				return nil
+			}
+		}
+		if errors.Is(err, ErrInvalidClaim) {
+			return fmt.Errorf("claim %s: %w", r.to, err)
+		}
+		return fmt.Errorf("claim %s: %w, the expression does not apply to the types of the claims", r.to, ErrInvalidClaim)
+	}
+
+	v, err := native(out)
+	if err != nil {
+		return fmt.Errorf("claim %s: %w", r.to, err)
+	}
+	store(claims, r.to, v)
+	return nil
+}
+
+// native converts a CEL value into the JSON types of the claims.
+func native(v ref.Val) (interface{}, error) {
+	switch t := v.(type) {
+	case types.Timestamp:
+		return t.Time.UTC().Format(time.RFC3339), nil
+	case types.Int:
+		return int64(t), nil
+	}
+
+	pb, err := v.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, err
+	}
+	return pb.(*structpb.Value).AsInterface(), nil
+}
@@ -0,0 +1,78 @@
+package mapping
+
+import (
+	"fmt"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+type entry struct {
+	tenant        string
+	configuration string
+	rules         []rule
+}
+
+// Mapper applies the claim mapping rules of a credential configuration. A nil Mapper maps nothing.
+type Mapper struct {
+	entries []entry
+}
+
+// New compiles the mappings configured in conf.Mappings, expressions are checked at startup.
+func New(conf config.Config) (*Mapper, error) {
+	m := &Mapper{}
+
+	for i, c := range conf.Mappings {
+		e := entry{tenant: c.Tenant, configuration: c.Configuration}
+		for j, rc := range c.Rules {
+			r, err := newRule(rc)
+			if err != nil {
+				return nil, fmt.Errorf("mappings[%d].rules[%d]: %w", i, j, err)
+			}
+			e.rules = append(e.rules, r)
+		}
+		m.entries = append(m.entries, e)
+	}
+
+	return m, nil
+}
+
+// rules returns the rules of the configuration, those configured for the tenant before those for all tenants.
+func (m *Mapper) rules(tenantId string, configurationId string) []rule {
+	if m == nil {
+		return nil
+	}
+
+	var fallback []rule
+	for _, e := range m.entries {
+		if e.configuration != configurationId {
+			continue
+		}
+		if e.tenant == tenantId {
+			return e.rules
+		}
+		if e.tenant == "" && fallback == nil {
+			fallback = e.rules
+		}
+	}
+	return fallback
+}
+
+// Apply returns the claims mapped by the rules in order. The input is not changed.
+func (m *Mapper) Apply(tenantId string, configurationId string, claims map[string]interface{}) (map[string]interface{}, error) {
+	rules := m.rules(tenantId, configurationId)
+	if len(rules) == 0 {
+		return claims, nil
+	}
+
+	mapped, _ := clone(claims).(map[string]interface{})
+	if mapped == nil {
+		mapped = map[string]interface{}{}
+	}
+
+	for _, r := range rules {
+		if err := r.apply(mapped); err != nil {
+			return nil, err
+		}
+	}
+	return mapped, nil
+}
@@ -0,0 +1,252 @@
+package mapping
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func TestRules(t *testing.T) {
+	adult := time.Now().AddDate(-30, 0, 0).Format(time.DateOnly)
+	minor := time.Now().AddDate(-10, 0, 0).Format(time.DateOnly)
+
+	tests := []struct {
+		name    string
+		rule    config.RuleConfig
+		claims  map[string]interface{}
+		want    map[string]interface{}
+		wantErr error
+	}{
+		{
+			name:   "rename",
+			rule:   config.RuleConfig{Op: OpRename, From: "firstName", To: "given_name"},
+			claims: map[string]interface{}{"firstName": "Jane"},
+			want:   map[string]interface{}{"given_name": "Jane"},
+		},
+		{
+			name:   "rename into path",
+			rule:   config.RuleConfig{Op: OpRename, From: "city", To: "address.locality"},
+			claims: map[string]interface{}{"city": "Berlin"},
+			want:   map[string]interface{}{"address": map[string]interface{}{"locality": "Berlin"}},
+		},
+		{
+			name:   "rename missing claim",
+			rule:   config.RuleConfig{Op: OpRename, From: "firstName", To: "given_name"},
+			claims: map[string]interface{}{"other": 1},
+			want:   map[string]interface{}{"other": 1},
+		},
+		{
+			name:   "nest",
+			rule:   config.RuleConfig{Op: OpNest, Fields: []string{"street", "city", "zip"}, To: "address"},
+			claims: map[string]interface{}{"street": "Main St", "city": "Berlin"},
+			want:   map[string]interface{}{"address": map[string]interface{}{"street": "Main St", "city": "Berlin"}},
+		},
+		{
+			name:   "constant",
+			rule:   config.RuleConfig{Op: OpConstant, To: "nationality", Value: "DE"},
+			claims: map[string]interface{}{},
+			want:   map[string]interface{}{"nationality": "DE"},
+		},
+		{
+			name:   "concat skips missing and empty claims",
+			rule:   config.RuleConfig{Op: OpConcat, Fields: []string{"given_name", "middle_name", "family_name"}, Separator: " ", To: "name"},
+			claims: map[string]interface{}{"given_name": "Jane", "middle_name": "", "family_name": "Doe"},
+			want:   map[string]interface{}{"given_name": "Jane", "middle_name": "", "family_name": "Doe", "name": "Jane Doe"},
+		},
+		{
+			name:   "concat of missing claims",
+			rule:   config.RuleConfig{Op: OpConcat, Fields: []string{"given_name"}, To: "name"},
+			claims: map[string]interface{}{},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:   "date with layout",
+			rule:   config.RuleConfig{Op: OpDate, From: "dob", To: "birthdate", Layout: "02.01.2006", Format: time.DateOnly},
+			claims: map[string]interface{}{"dob": "31.12.1999"},
+			want:   map[string]interface{}{"dob": "31.12.1999", "birthdate": "1999-12-31"},
+		},
+		{
+			name:   "date in place with default layouts",
+			rule:   config.RuleConfig{Op: OpDate, From: "dob", Format: "02.01.2006"},
+			claims: map[string]interface{}{"dob": "1999-12-31"},
+			want:   map[string]interface{}{"dob": "31.12.1999"},
+		},
+		{
+			name:   "date of missing claim",
+			rule:   config.RuleConfig{Op: OpDate, From: "dob", Format: time.DateOnly},
+			claims: map[string]interface{}{},
+			want:   map[string]interface{}{},
+		},
+		{
+			name:    "invalid date",
+			rule:    config.RuleConfig{Op: OpDate, From: "dob", Layout: time.DateOnly, Format: time.DateOnly},
+			claims:  map[string]interface{}{"dob": "31.12.1999"},
+			wantErr: ErrInvalidClaim,
+		},
+		{
+			name:   "expr",
+			rule:   config.RuleConfig{Op: OpExpr, To: "age_over_18", Expr: "age(claims.birthdate) >= 18"},
+			claims: map[string]interface{}{"birthdate": adult},
+			want:   map[string]interface{}{"birthdate": adult, "age_over_18": true},
+		},
+		{
+			name:   "expr false",
+			rule:   config.RuleConfig{Op: OpExpr, To: "age_over_18", Expr: "age(claims.birthdate) >= 18"},
+			claims: map[string]interface{}{"birthdate": minor},
+			want:   map[string]interface{}{"birthdate": minor, "age_over_18": false},
+		},
+		{
+			name:   "expr of missing claim",
+			rule:   config.RuleConfig{Op: OpExpr, To: "age_over_18", Expr: "age(claims.birthdate) >= 18"},
+			claims: map[string]interface{}{"name": "Jane"},
+			want:   map[string]interface{}{"name": "Jane"},
+		},
+		{
+			name:   "expr of missing nested claim",
+			rule:   config.RuleConfig{Op: OpExpr, To: "city", Expr: "claims.address.city"},
+			claims: map[string]interface{}{"address": map[string]interface{}{}},
+			want:   map[string]interface{}{"address": map[string]interface{}{}},
+		},
+		{
+			name:    "expr of claim with wrong type",
+			rule:    config.RuleConfig{Op: OpExpr, To: "age_over_18", Expr: "age(claims.birthdate) >= 18"},
+			claims:  map[string]interface{}{"birthdate": 19991231},
+			wantErr: ErrInvalidClaim,
+		},
+		{
+			name:    "expr of claim that is no date",
+			rule:    config.RuleConfig{Op: OpExpr, To: "age_over_18", Expr: "age(claims.birthdate) >= 18"},
+			claims:  map[string]interface{}{"birthdate": "yesterday"},
+			wantErr: ErrInvalidClaim,
+		},
+		{
+			name:   "expr guarding a missing claim",
+			rule:   config.RuleConfig{Op: OpExpr, To: "display_name", Expr: `has(claims.nickname) ? claims.nickname : claims.name`},
+			claims: map[string]interface{}{"name": "Jane"},
+			want:   map[string]interface{}{"name": "Jane", "display_name": "Jane"},
+		},
+		{
+			name:    "expr failing on present claims",
+			rule:    config.RuleConfig{Op: OpExpr, To: "share", Expr: "claims.total / claims.parts"},
+			claims:  map[string]interface{}{"total": 4, "parts": 0},
+			wantErr: ErrInvalidClaim,
+		},
+		{
+			name:   "expr of age at a time",
+			rule:   config.RuleConfig{Op: OpExpr, To: "age", Expr: `age(claims.birthdate, timestamp("2020-05-31T00:00:00Z"))`},
+			claims: map[string]interface{}{"birthdate": "2000-06-01"},
+			want:   map[string]interface{}{"birthdate": "2000-06-01", "age": int64(19)},
+		},
+		{
+			name:   "expr of age at now",
+			rule:   config.RuleConfig{Op: OpExpr, To: "same", Expr: "age(claims.birthdate) == age(claims.birthdate, now)"},
+			claims: map[string]interface{}{"birthdate": adult},
+			want:   map[string]interface{}{"birthdate": adult, "same": true},
+		},
+		{
+			name:   "expr with int result",
+			rule:   config.RuleConfig{Op: OpExpr, To: "count", Expr: "size(claims.items)"},
+			claims: map[string]interface{}{"items": []interface{}{"a", "b"}},
+			want:   map[string]interface{}{"items": []interface{}{"a", "b"}, "count": int64(2)},
+		},
+		{
+			name:   "remove",
+			rule:   config.RuleConfig{Op: OpRemove, From: "address.zip"},
+			claims: map[string]interface{}{"address": map[string]interface{}{"zip": "10115", "city": "Berlin"}},
+			want:   map[string]interface{}{"address": map[string]interface{}{"city": "Berlin"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(config.Config{Mappings: []config.MappingConfig{{Configuration: "c1", Rules: []config.RuleConfig{tt.rule}}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := m.Apply("t1", "c1", tt.claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleErrorsLeaveOutClaimValues(t *testing.T) {
+	m, err := New(config.Config{Mappings: []config.MappingConfig{{Configuration: "c1", Rules: []config.RuleConfig{
+		{Op: OpDate, From: "dob", Layout: "02.01.2006", Format: time.DateOnly},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Apply("t1", "c1", map[string]interface{}{"dob": "1999-12-31"})
+	if err == nil || strings.Contains(err.Error(), "1999-12-31") {
+		t.Errorf("Apply() error = %v, want an error without the claim value", err)
+	}
+}
+
+func TestNewRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.RuleConfig
+	}{
+		{name: "unknown op", rule: config.RuleConfig{Op: "split"}},
+		{name: "rename without to", rule: config.RuleConfig{Op: OpRename, From: "a"}},
+		{name: "nest without fields", rule: config.RuleConfig{Op: OpNest, To: "a"}},
+		{name: "date without format", rule: config.RuleConfig{Op: OpDate, From: "a"}},
+		{name: "invalid expression", rule: config.RuleConfig{Op: OpExpr, To: "a", Expr: "claims.a >"}},
+		{name: "unknown function", rule: config.RuleConfig{Op: OpExpr, To: "a", Expr: "years(claims.a)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(config.Config{Mappings: []config.MappingConfig{{Configuration: "c1", Rules: []config.RuleConfig{tt.rule}}}}); err == nil {
+				t.Error("New() accepted the rule")
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	m, err := New(config.Config{Mappings: []config.MappingConfig{
+		{Configuration: "c1", Rules: []config.RuleConfig{{Op: OpConstant, To: "scope", Value: "all"}}},
+		{Configuration: "c1", Tenant: "t1", Rules: []config.RuleConfig{{Op: OpConstant, To: "scope", Value: "t1"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tenantId, configurationId string
+		want                      interface{}
+	}{
+		{"t1", "c1", "t1"},
+		{"t2", "c1", "all"},
+		{"t1", "c2", nil},
+	}
+
+	for _, tt := range tests {
+		input := map[string]interface{}{"name": "Jane"}
+		got, err := m.Apply(tt.tenantId, tt.configurationId, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["scope"] != tt.want {
+			t.Errorf("Apply(%s, %s) scope = %v, want %v", tt.tenantId, tt.configurationId, got["scope"], tt.want)
+		}
+		if _, changed := input["scope"]; changed {
+			t.Errorf("Apply(%s, %s) changed the input", tt.tenantId, tt.configurationId)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package mapping
+
+import "strings"
+
+// Claims are addressed by dotted paths, e.g. address.city.
+
+func lookup(claims map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	current := claims
+	for i, p := range parts {
+		v, ok := current[p]
+		if !ok {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		if current, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// store sets the claim at path, creating the objects on the way. Non-object values on the way are replaced.
+func store(claims map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	current := claims
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := current[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[p] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+func remove(claims map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	current := claims
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := current[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, parts[len(parts)-1])
+}
+
+// clone copies the objects and lists of the claims so that rules do not change the input.
+func clone(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			c[k] = clone(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, e := range t {
+			c[i] = clone(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
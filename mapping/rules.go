@@ -0,0 +1,181 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Rule operations.
+const (
+	OpRename   = "rename"
+	OpNest     = "nest"
+	OpConstant = "constant"
+	OpConcat   = "concat"
+	OpDate     = "date"
+	OpExpr     = "expr"
+	OpRemove   = "remove"
+)
+
+// ErrInvalidClaim is returned by rules for claims of the wrong type or format.
+var ErrInvalidClaim = errors.New("invalid claim")
+
+// dateLayouts are tried in order when a date rule has no layout.
+var dateLayouts = []string{time.DateOnly, time.RFC3339, "02.01.2006", "01/02/2006"}
+
+type rule interface {
+	apply(claims map[string]interface{}) error
+}
+
+func newRule(c config.RuleConfig) (rule, error) {
+	switch c.Op {
+	case OpRename:
+		if c.From == "" || c.To == "" {
+			return nil, fmt.Errorf("%s needs from and to", c.Op)
+		}
+		return renameRule{from: c.From, to: c.To}, nil
+	case OpNest:
+		if len(c.Fields) == 0 || c.To == "" {
+			return nil, fmt.Errorf("%s needs fields and to", c.Op)
+		}
+		return nestRule{fields: c.Fields, to: c.To}, nil
+	case OpConstant:
+		if c.To == "" {
+			return nil, fmt.Errorf("%s needs to", c.Op)
+		}
+		return constantRule{to: c.To, value: c.Value}, nil
+	case OpConcat:
+		if len(c.Fields) == 0 || c.To == "" {
+			return nil, fmt.Errorf("%s needs fields and to", c.Op)
+		}
+		return concatRule{fields: c.Fields, separator: c.Separator, to: c.To}, nil
+	case OpDate:
+		if c.From == "" || c.Format == "" {
+			return nil, fmt.Errorf("%s needs from and format", c.Op)
+		}
+		to := c.To
+		if to == "" {
+			to = c.From
+		}
+		return dateRule{from: c.From, to: to, layout: c.Layout, format: c.Format}, nil
+	case OpExpr:
+		if c.Expr == "" || c.To == "" {
+			return nil, fmt.Errorf("%s needs expr and to", c.Op)
+		}
+		return newExprRule(c.To, c.Expr)
+	case OpRemove:
+		if c.From == "" {
+			return nil, fmt.Errorf("%s needs from", c.Op)
+		}
+		return removeRule{from: c.From}, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", c.Op)
+	}
+}
+
+// Rules reading a missing claim are skipped, so that optional claims need no special handling.
+
+type renameRule struct{ from, to string }
+
+func (r renameRule) apply(claims map[string]interface{}) error {
+	v, ok := lookup(claims, r.from)
+	if !ok {
+		return nil
+	}
+	remove(claims, r.from)
+	store(claims, r.to, v)
+	return nil
+}
+
+type nestRule struct {
+	fields []string
+	to     string
+}
+
+func (r nestRule) apply(claims map[string]interface{}) error {
+	for _, f := range r.fields {
+		v, ok := lookup(claims, f)
+		if !ok {
+			continue
+		}
+		remove(claims, f)
+		store(claims, r.to+"."+f[strings.LastIndex(f, ".")+1:], v)
+	}
+	return nil
+}
+
+type constantRule struct {
+	to    string
+	value interface{}
+}
+
+func (r constantRule) apply(claims map[string]interface{}) error {
+	store(claims, r.to, clone(r.value))
+	return nil
+}
+
+type concatRule struct {
+	fields    []string
+	separator string
+	to        string
+}
+
+func (r concatRule) apply(claims map[string]interface{}) error {
+	parts := make([]string, 0, len(r.fields))
+	for _, f := range r.fields {
+		if v, ok := lookup(claims, f); ok && v != nil && fmt.Sprint(v) != "" {
+			parts = append(parts, fmt.Sprint(v))
+		}
+	}
+	if len(parts) > 0 {
+		store(claims, r.to, strings.Join(parts, r.separator))
+	}
+	return nil
+}
+
+type dateRule struct {
+	from, to       string
+	layout, format string
+}
+
+func (r dateRule) apply(claims map[string]interface{}) error {
+	v, ok := lookup(claims, r.from)
+	if !ok {
+		return nil
+	}
+
+	t, err := parseDate(fmt.Sprint(v), r.layout)
+	if err != nil {
+		return fmt.Errorf("claim %s: %w: %w", r.from, ErrInvalidClaim, err)
+	}
+	store(claims, r.to, t.Format(r.format))
+	return nil
+}
+
+// parseDate reads a date in the layout or one of the date layouts. The errors leave out the value,
+// claims are personal data.
+func parseDate(s string, layout string) (time.Time, error) {
+	if layout != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("not a date in the layout %q", layout)
+		}
+		return t, nil
+	}
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not a date in one of the layouts %v", dateLayouts)
+}
+
+type removeRule struct{ from string }
+
+func (r removeRule) apply(claims map[string]interface{}) error {
+	remove(claims, r.from)
+	return nil
+}
@@ -0,0 +1,7 @@
+package metadata
+
+// Credential response encryption parameters the module accepts from wallets.
+var (
+	ResponseEncryptionAlgValues = []string{"ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A256KW", "RSA-OAEP-256"}
+	ResponseEncryptionEncValues = []string{"A128GCM", "A256GCM", "A128CBC-HS256", "A256CBC-HS512"}
+)
@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// SupportedAlgorithms are the signing algorithms the signer service can be asked for.
+var SupportedAlgorithms = []string{"ES256", "EdDSA", "PS256"}
+
+// SigningKey addresses a key in the signer service.
+type SigningKey struct {
+	Namespace string
+	Group     string
+	Key       string
+	Algorithm string
+}
+
+// SigningKeyFor resolves the key of a tenant for a credential configuration. A key mapped to the
+// configuration wins over the tenant default, which wins over SIGNERKEY in the tenant namespace.
+// Without a mapped algorithm the first signing algorithm advertised by the configuration is used.
+func SigningKeyFor(conf config.Config, tenantId string, configurationId string) (SigningKey, error) {
+	c, ok := Registration.Issuer.CredentialConfigurationsSupported[configurationId]
+	if !ok {
+		return SigningKey{}, fmt.Errorf("%w: %q is not a credential configuration of this issuer", ErrConfigurationDisabled, configurationId)
+	}
+
+	key := SigningKey{Namespace: tenantId, Key: conf.SignerKey}
+
+	if t, err := Tenant(conf, tenantId); err == nil {
+		if k, ok := keyMapping(t, configurationId); ok {
+			if k.Namespace != "" {
+				key.Namespace = k.Namespace
+			}
+			key.Group = k.Group
+			key.Key = k.Key
+			key.Algorithm = k.Algorithm
+		}
+	}
+
+	if key.Algorithm == "" && len(c.CredentialSigningAlgValuesSupported) > 0 {
+		key.Algorithm = c.CredentialSigningAlgValuesSupported[0]
+	}
+
+	if len(c.CredentialSigningAlgValuesSupported) > 0 && !slices.Contains(c.CredentialSigningAlgValuesSupported, key.Algorithm) {
+		return key, fmt.Errorf("signing algorithm %s of tenant %q is not advertised for %q, expected one of %v",
+			key.Algorithm, tenantId, configurationId, c.CredentialSigningAlgValuesSupported)
+	}
+
+	return key, nil
+}
+
+func keyMapping(t config.TenantConfig, configurationId string) (config.KeyConfig, bool) {
+	var fallback *config.KeyConfig
+
+	for i, k := range t.Keys {
+		if k.Configuration == configurationId {
+			return k, true
+		}
+		if k.Configuration == "" && fallback == nil {
+			fallback = &t.Keys[i]
+		}
+	}
+
+	if fallback != nil {
+		return *fallback, true
+	}
+	return config.KeyConfig{}, false
+}
+
+func validateKeys(i int, t config.TenantConfig) []error {
+	var errs []error
+
+	for j, k := range t.Keys {
+		field := fmt.Sprintf("tenants[%d].keys[%d]", i, j)
+
+		if k.Key == "" {
+			errs = append(errs, fmt.Errorf("%s.key is required", field))
+		}
+		if k.Algorithm != "" && !slices.Contains(SupportedAlgorithms, k.Algorithm) {
+			errs = append(errs, fmt.Errorf("%s.algorithm %q is not supported, expected one of %v", field, k.Algorithm, SupportedAlgorithms))
+		}
+		if k.Configuration == "" {
+			continue
+		}
+
+		c, ok := Registration.Issuer.CredentialConfigurationsSupported[k.Configuration]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.configuration %q is not a credential configuration", field, k.Configuration))
+			continue
+		}
+		if k.Algorithm != "" && !slices.Contains(c.CredentialSigningAlgValuesSupported, k.Algorithm) {
+			errs = append(errs, fmt.Errorf("%s.algorithm %s is not advertised for %q, expected one of %v",
+				field, k.Algorithm, k.Configuration, c.CredentialSigningAlgValuesSupported))
+		}
+	}
+
+	return errs
+}
@@ -1,10 +1,12 @@
 package metadata
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/cloudevents/sdk-go/v2/event"
 	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
 	messaging "github.com/eclipse-xfsc/nats-message-library"
 	"github.com/eclipse-xfsc/nats-message-library/common"
@@ -28,6 +30,8 @@ var Registration = messaging.IssuerRegistration{
 		AuthorizationServers: []string{"https://auth-cloud-wallet.xfsc.dev/realms/master", "https://cloud-wallet.xfsc.dev"},
 		CredentialEndpoint:   "https://cloud-wallet.xfsc.dev/api/issuance/credential",
 		CredentialResponseEncryption: credential.CredentialRespEnc{
+			AlgValuesSupported: ResponseEncryptionAlgValues,
+			EncValuesSupported: ResponseEncryptionEncValues,
 			EncryptionRequired: false,
 		},
 		Display: []credential.LocalizedCredential{
@@ -182,19 +186,7 @@ var Registration = messaging.IssuerRegistration{
 	},
 }
 
-func Publish(conf config.Config) {
-
-	if conf.Credential_Issuer != "" {
-		Registration.Issuer.CredentialIssuer = conf.Credential_Issuer
-	}
-
-	if conf.Authorization_Server != nil && len(conf.Authorization_Server) > 0 {
-		Registration.Issuer.AuthorizationServers = conf.Authorization_Server
-	}
-
-	if conf.Credential_Endpoint != "" {
-		Registration.Issuer.CredentialEndpoint = conf.Credential_Endpoint
-	}
+func Publish(ctx context.Context, conf config.Config) {
 
 	client, err := cloudeventprovider.New(
 		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: conf.Nats},
@@ -205,26 +197,42 @@ func Publish(conf config.Config) {
 		panic(err)
 	}
 
+	defer client.Close()
+
 	interval := time.NewTicker(time.Second * 30)
+	defer interval.Stop()
 
-	data, err := json.Marshal(Registration)
-	if err != nil {
-		panic(err)
-	}
+	tenants := Tenants(conf)
+	events := make([]event.Event, 0, len(tenants))
 
-	event, err := cloudeventprovider.NewEvent("test-issuer", messaging.EventTypeIssuerRegistration, data)
-	if err != nil {
-		panic(err)
+	for _, t := range tenants {
+		data, err := json.Marshal(ForTenant(conf, t))
+		if err != nil {
+			panic(err)
+		}
+
+		event, err := cloudeventprovider.NewEvent("test-issuer", messaging.EventTypeIssuerRegistration, data)
+		if err != nil {
+			panic(err)
+		}
+
+		events = append(events, event)
 	}
 
 	for {
-		<-interval.C
-
-		if err := client.Pub(event); err != nil {
-			log.Printf("%+v", err)
-			continue
+		select {
+		case <-ctx.Done():
+			return
+		case <-interval.C:
 		}
 
-		//log.Printf("send event: %s", Registration.Issuer.CredentialIssuer)
+		for i, event := range events {
+			if err := client.PubCtx(ctx, event); err != nil {
+				slog.Error("issuer registration could not be published", "tenant_id", tenants[i].Id, "error", err)
+				continue
+			}
+
+			slog.Debug("issuer registration published", "tenant_id", tenants[i].Id, "credential_issuer", IssuerUrl(tenants[i]))
+		}
 	}
 }
@@ -0,0 +1,169 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	messaging "github.com/eclipse-xfsc/nats-message-library"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+	"github.com/google/uuid"
+)
+
+// DefaultTenant is published with the top level issuer settings when no tenants are configured.
+const DefaultTenant = "tenant_space"
+
+var (
+	ErrUnknownTenant         = errors.New("unknown tenant")
+	ErrConfigurationDisabled = errors.New("credential configuration is not enabled for tenant")
+)
+
+// Tenants returns the configured tenants, or the default tenant if none are configured. Empty issuer
+// settings of a tenant are taken from the top level settings.
+func Tenants(conf config.Config) []config.TenantConfig {
+	if len(conf.Tenants) == 0 {
+		return []config.TenantConfig{withDefaults(conf, config.TenantConfig{Id: DefaultTenant})}
+	}
+
+	tenants := make([]config.TenantConfig, 0, len(conf.Tenants))
+	for _, t := range conf.Tenants {
+		tenants = append(tenants, withDefaults(conf, t))
+	}
+	return tenants
+}
+
+// Tenant returns the tenant with the id. Without configured tenants every tenant id is accepted and
+// served with the top level settings.
+func Tenant(conf config.Config, tenantId string) (config.TenantConfig, error) {
+	if len(conf.Tenants) == 0 {
+		return withDefaults(conf, config.TenantConfig{Id: tenantId}), nil
+	}
+
+	for _, t := range conf.Tenants {
+		if t.Id == tenantId {
+			return withDefaults(conf, t), nil
+		}
+	}
+
+	return config.TenantConfig{}, fmt.Errorf("%w: %q", ErrUnknownTenant, tenantId)
+}
+
+func withDefaults(conf config.Config, t config.TenantConfig) config.TenantConfig {
+	if t.CredentialIssuer == "" {
+		t.CredentialIssuer = conf.Credential_Issuer
+	}
+	if len(t.AuthorizationServers) == 0 {
+		t.AuthorizationServers = conf.Authorization_Server
+	}
+	if t.CredentialEndpoint == "" {
+		t.CredentialEndpoint = conf.Credential_Endpoint
+	}
+	return t
+}
+
+// Enabled returns the tenant if it may issue the credential configuration.
+func Enabled(conf config.Config, tenantId string, configurationId string) (config.TenantConfig, error) {
+	t, err := Tenant(conf, tenantId)
+	if err != nil {
+		return t, err
+	}
+
+	if _, ok := Registration.Issuer.CredentialConfigurationsSupported[configurationId]; !ok {
+		return t, fmt.Errorf("%w: %q is not a credential configuration of this issuer", ErrConfigurationDisabled, configurationId)
+	}
+
+	if len(t.Configurations) > 0 && !slices.Contains(t.Configurations, configurationId) {
+		return t, fmt.Errorf("%w: %q is not enabled for %q", ErrConfigurationDisabled, configurationId, tenantId)
+	}
+
+	return t, nil
+}
+
+// Subject is the NATS subject prefix the credential configuration is served on, without the suffix
+// of the operation.
+func Subject(conf config.Config, configurationId string) (string, error) {
+	c, ok := Registration.Issuer.CredentialConfigurationsSupported[configurationId]
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not a credential configuration of this issuer", ErrConfigurationDisabled, configurationId)
+	}
+	return conf.SubjectPrefix + c.Subject, nil
+}
+
+// IssuerUrl is the credential issuer of the tenant as published in its metadata.
+func IssuerUrl(t config.TenantConfig) string {
+	if t.CredentialIssuer != "" {
+		return t.CredentialIssuer
+	}
+	return Registration.Issuer.CredentialIssuer
+}
+
+// ForTenant derives the registration of one tenant from the shared Registration. The subjects are
+// those the module serves, with SUBJECT_PREFIX.
+func ForTenant(conf config.Config, t config.TenantConfig) messaging.IssuerRegistration {
+	r := Registration
+	r.Request.TenantId = t.Id
+	r.Request.RequestId = uuid.NewString()
+
+	r.Issuer.CredentialIssuer = IssuerUrl(t)
+
+	if len(t.AuthorizationServers) > 0 {
+		r.Issuer.AuthorizationServers = t.AuthorizationServers
+	}
+
+	if t.CredentialEndpoint != "" {
+		r.Issuer.CredentialEndpoint = t.CredentialEndpoint
+	}
+
+	r.Issuer.CredentialResponseEncryption.EncryptionRequired = t.RequireEncryption
+
+	if len(t.Display) > 0 {
+		r.Issuer.Display = make([]credential.LocalizedCredential, 0, len(t.Display))
+		for _, d := range t.Display {
+			r.Issuer.Display = append(r.Issuer.Display, credential.LocalizedCredential{Name: d.Name, Locale: d.Locale})
+		}
+	}
+
+	r.Issuer.CredentialConfigurationsSupported = maps.Clone(Registration.Issuer.CredentialConfigurationsSupported)
+	for id, c := range r.Issuer.CredentialConfigurationsSupported {
+		c.Subject, _ = Subject(conf, id)
+		r.Issuer.CredentialConfigurationsSupported[id] = c
+	}
+	if len(t.Configurations) > 0 {
+		maps.DeleteFunc(r.Issuer.CredentialConfigurationsSupported, func(id string, _ credential.CredentialConfiguration) bool {
+			return !slices.Contains(t.Configurations, id)
+		})
+	}
+
+	return r
+}
+
+// Validate checks the tenant settings against the credential configurations of this issuer.
+func Validate(conf config.Config) error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i, t := range conf.Tenants {
+		if t.Id == "" {
+			errs = append(errs, fmt.Errorf("tenants[%d].id is required", i))
+		} else if seen[t.Id] {
+			errs = append(errs, fmt.Errorf("tenants[%d].id %q is configured twice", i, t.Id))
+		}
+		seen[t.Id] = true
+
+		errs = append(errs, validateKeys(i, t)...)
+
+		for _, id := range t.Configurations {
+			if _, ok := Registration.Issuer.CredentialConfigurationsSupported[id]; !ok {
+				errs = append(errs, fmt.Errorf("tenants[%d].configurations: %q is not a credential configuration, expected one of %v",
+					i, id, slices.Sorted(maps.Keys(Registration.Issuer.CredentialConfigurationsSupported))))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid tenant configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+func TestTenant(t *testing.T) {
+	conf := config.Default()
+	conf.Credential_Issuer = "https://issuer.example.org"
+
+	tests := []struct {
+		name       string
+		tenants    []config.TenantConfig
+		tenantId   string
+		wantIssuer string
+		wantErr    error
+	}{
+		{name: "any tenant without tenants", tenantId: "acme", wantIssuer: "https://issuer.example.org"},
+		{name: "default tenant without tenants", tenantId: DefaultTenant, wantIssuer: "https://issuer.example.org"},
+		{
+			name:       "configured tenant falls back to CREDENTIAL_ISSUER",
+			tenants:    []config.TenantConfig{{Id: "acme"}},
+			tenantId:   "acme",
+			wantIssuer: "https://issuer.example.org",
+		},
+		{
+			name:       "configured tenant with own issuer",
+			tenants:    []config.TenantConfig{{Id: "acme", CredentialIssuer: "https://acme.example.org"}},
+			tenantId:   "acme",
+			wantIssuer: "https://acme.example.org",
+		},
+		{
+			name:     "unknown tenant",
+			tenants:  []config.TenantConfig{{Id: "acme"}},
+			tenantId: "other",
+			wantErr:  ErrUnknownTenant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := conf
+			c.Tenants = tt.tenants
+
+			got, err := Tenant(c, tt.tenantId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Tenant() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Id != tt.tenantId {
+				t.Errorf("Tenant() id = %q, want %q", got.Id, tt.tenantId)
+			}
+			if IssuerUrl(got) != tt.wantIssuer {
+				t.Errorf("IssuerUrl() = %q, want %q", IssuerUrl(got), tt.wantIssuer)
+			}
+		})
+	}
+}
+
+func TestSigningKeyForUnconfiguredTenant(t *testing.T) {
+	conf := config.Default()
+	conf.SignerKey = "signerkey"
+
+	key, err := SigningKeyFor(conf, "acme", Credential_Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Namespace != "acme" || key.Key != "signerkey" {
+		t.Errorf("SigningKeyFor() = %+v, want namespace acme and key signerkey", key)
+	}
+}
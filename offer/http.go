@@ -0,0 +1,77 @@
+package offer
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Handler serves GET /credential-offer/{id} to wallets resolving a credential_offer_uri,
+// GET /credential-offer/{id}/qr with the QR code of the by-reference offer and
+// GET /qr?offer= with the QR code of a by-reference offer of the store, the QR codes as format=png or svg in size pixels.
+// Offers by value are rendered on the administrative listener, see issuance.QRHandler.
+func Handler(s *Store) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /credential-offer/{id}", func(w http.ResponseWriter, r *http.Request) {
+		params, _, err := s.Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(params)
+	})
+
+	mux.HandleFunc("GET /credential-offer/{id}/qr", func(w http.ResponseWriter, r *http.Request) {
+		_, offer, err := s.Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		WriteQR(w, r, offer)
+	})
+
+	// only offers of the store are rendered, the route must not turn arbitrary text into images
+	mux.HandleFunc("GET /qr", func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.Lookup(r.URL.Query().Get("offer"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		_, offer, err := s.Get(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		WriteQR(w, r, offer)
+	})
+
+	return mux
+}
+
+// WriteQR answers the QR code of the content in the format and size of the query.
+func WriteQR(w http.ResponseWriter, r *http.Request, content string) {
+	q := r.URL.Query()
+
+	size := 0
+	if v := q.Get("size"); v != "" {
+		var err error
+		if size, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "size must be a number of pixels", http.StatusBadRequest)
+			return
+		}
+	}
+
+	b, contentType, err := QR(content, q.Get("format"), size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(b)
+}
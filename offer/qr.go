@@ -0,0 +1,61 @@
+package offer
+
+import (
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Image formats of QR codes.
+const (
+	FormatPng = "png"
+	FormatSvg = "svg"
+)
+
+const (
+	DefaultSize = 256
+	MaxSize     = 2048
+)
+
+// QR renders the content as QR code of size pixels and returns the image with its content type.
+func QR(content string, format string, size int) ([]byte, string, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if size > MaxSize {
+		return nil, "", fmt.Errorf("size must not exceed %d, got %d", MaxSize, size)
+	}
+
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch format {
+	case FormatPng, "":
+		b, err := q.PNG(size)
+		return b, "image/png", err
+	case FormatSvg:
+		return svg(q.Bitmap(), size), "image/svg+xml", nil
+	default:
+		return nil, "", fmt.Errorf("format must be %s or %s, got %q", FormatPng, FormatSvg, format)
+	}
+}
+
+// svg draws the dark modules as one path, the bitmap includes the quiet zone.
+func svg(bitmap [][]bool, size int) []byte {
+	var b strings.Builder
+	n := len(bitmap)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
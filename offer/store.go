@@ -0,0 +1,149 @@
+package offer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("no credential offer found")
+
+type stored struct {
+	params  []byte
+	offer   string
+	expires time.Time
+}
+
+// Store keeps credential offers for wallets resolving a credential_offer_uri. Offers are dropped after
+// the storage TTL like the credentials they refer to. A nil Store passes offers by value.
+type Store struct {
+	baseUrl string
+	ttl     time.Duration
+	mu      sync.Mutex
+	offers  map[string]stored
+}
+
+// New returns a Store if OFFERS_BY_REFERENCE is set, nil otherwise.
+func New(conf config.Config) *Store {
+	if !conf.Offers.ByReference {
+		return nil
+	}
+
+	return &Store{
+		baseUrl: strings.TrimSuffix(conf.Offers.BaseUrl, "/"),
+		ttl:     conf.Storage.TTL,
+		offers:  make(map[string]stored),
+	}
+}
+
+// Reference stores the parameters of the offer and returns the offer pointing to them with
+// credential_offer_uri instead.
+func (s *Store) Reference(offer credential.CredentialOffer) (credential.CredentialOffer, error) {
+	if s == nil {
+		return offer, nil
+	}
+
+	u, err := url.Parse(offer.CredentialOffer)
+	if err != nil {
+		return offer, err
+	}
+	params := u.Query().Get("credential_offer")
+	if params == "" || !json.Valid([]byte(params)) {
+		return offer, errors.New("credential offer carries no credential_offer parameters")
+	}
+
+	// the scheme of the offer is kept as is, url.URL drops the // of openid-credential-offer://
+	id := uuid.NewString()
+	prefix, _, _ := strings.Cut(offer.CredentialOffer, "?")
+	referenced := prefix + "?" + url.Values{"credential_offer_uri": {s.Uri(id)}}.Encode()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offers[id] = stored{params: []byte(params), offer: referenced, expires: time.Now().Add(s.ttl)}
+
+	offer.CredentialOffer = referenced
+	return offer, nil
+}
+
+// Expire drops the offers that expired before now.
+func (s *Store) Expire(now time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.offers {
+		if now.After(v.expires) {
+			delete(s.offers, k)
+		}
+	}
+}
+
+// Uri is the credential_offer_uri of the offer stored with the id.
+func (s *Store) Uri(id string) string {
+	return s.baseUrl + "/credential-offer/" + url.PathEscape(id)
+}
+
+// Lookup returns the id of a by-reference offer of this store, ErrNotFound for any other content.
+func (s *Store) Lookup(offer string) (string, error) {
+	if s == nil {
+		return "", ErrNotFound
+	}
+
+	u, err := url.Parse(offer)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	id, ok := strings.CutPrefix(u.Query().Get("credential_offer_uri"), s.baseUrl+"/credential-offer/")
+	if !ok {
+		return "", ErrNotFound
+	}
+	if id, err = url.PathUnescape(id); err != nil {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
+
+// Remove drops the by-reference offer of this store, so that wallets can no longer resolve it. Other
+// offers are ignored.
+func (s *Store) Remove(offer string) {
+	id, err := s.Lookup(offer)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.offers, id)
+}
+
+// Get returns the credential offer parameters as JSON and the by-reference offer pointing to them.
+func (s *Store) Get(id string) ([]byte, string, error) {
+	if s == nil {
+		return nil, "", ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.offers[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	if time.Now().After(v.expires) {
+		delete(s.offers, id)
+		return nil, "", ErrNotFound
+	}
+	return v.params, v.offer, nil
+}
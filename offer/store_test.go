@@ -0,0 +1,97 @@
+package offer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+)
+
+func newStore(t *testing.T, ttl time.Duration) (*Store, string) {
+	t.Helper()
+
+	conf := config.Default()
+	conf.Offers.ByReference = true
+	conf.Offers.BaseUrl = "https://issuer.example.com/"
+	conf.Storage.TTL = ttl
+	s := New(conf)
+
+	offer, err := s.Reference(credential.CredentialOffer{CredentialOffer: `openid-credential-offer://?credential_offer={"credential_issuer":"https://issuer.example.com"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, offer.CredentialOffer
+}
+
+func TestExpire(t *testing.T) {
+	s, offer := newStore(t, time.Hour)
+	id, err := s.Lookup(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Expire(time.Now())
+	if _, _, err := s.Get(id); err != nil {
+		t.Fatalf("offer dropped before it expired: %v", err)
+	}
+
+	s.Expire(time.Now().Add(2 * time.Hour))
+	if len(s.offers) != 0 {
+		t.Errorf("%d offers kept after they expired", len(s.offers))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s, offer := newStore(t, time.Hour)
+	id, err := s.Lookup(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Remove(`openid-credential-offer://?credential_offer={"credential_issuer":"x"}`)
+	if _, _, err := s.Get(id); err != nil {
+		t.Fatalf("offer dropped for another offer: %v", err)
+	}
+
+	s.Remove(offer)
+	if _, _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of removed offer error = %v, want %v", err, ErrNotFound)
+	}
+
+	var none *Store
+	none.Remove(offer)
+}
+
+func TestQR(t *testing.T) {
+	s, offer := newStore(t, time.Hour)
+
+	tests := []struct {
+		name       string
+		offer      string
+		wantStatus int
+	}{
+		{name: "stored offer", offer: offer, wantStatus: http.StatusOK},
+		// offers by value are rendered by request id on the administrative listener only
+		{name: "offer by value", offer: `openid-credential-offer://?credential_offer={"credential_issuer":"x"}`, wantStatus: http.StatusNotFound},
+		{name: "offer of another issuer", offer: "openid-credential-offer://?credential_offer_uri=https://evil.example.com/credential-offer/1", wantStatus: http.StatusNotFound},
+		{name: "unknown id", offer: "openid-credential-offer://?credential_offer_uri=https://issuer.example.com/credential-offer/1", wantStatus: http.StatusNotFound},
+		{name: "any text", offer: "hello", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/qr?offer="+url.QueryEscape(tt.offer), nil)
+			w := httptest.NewRecorder()
+			Handler(s).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"gopkg.in/yaml.v3"
+)
+
+// Operations a policy applies to.
+const (
+	OpRequest = "request"
+	OpIssue   = "issue"
+)
+
+var ErrDenied = errors.New("denied by policy")
+
+// env is the CEL environment of policies, see Input for the variables.
+var env = func() *cel.Env {
+	e, err := cel.NewEnv(
+		cel.Variable("operation", cel.StringType),
+		cel.Variable("tenant", cel.StringType),
+		cel.Variable("configuration", cel.StringType),
+		cel.Variable("payload", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("holder", cel.StringType),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}()
+
+// Input is what a policy can see of a request. Request holds request_id, group_id, source, event_type and,
+// on issue, format.
+type Input struct {
+	Operation     string
+	Tenant        string
+	Configuration string
+	Payload       map[string]interface{}
+	Holder        string
+	Request       map[string]string
+}
+
+// file is the YAML layout of a policy file.
+type file struct {
+	Policies []struct {
+		Name           string   `yaml:"name"`
+		Operations     []string `yaml:"operations"`
+		Tenants        []string `yaml:"tenants"`
+		Configurations []string `yaml:"configurations"`
+		Allow          string   `yaml:"allow"`
+		Deny           string   `yaml:"deny"`
+		Message        string   `yaml:"message"`
+	} `yaml:"policies"`
+}
+
+type rule struct {
+	name           string
+	operations     []string
+	tenants        []string
+	configurations []string
+	allow          cel.Program
+	deny           cel.Program
+	message        string
+}
+
+// Engine evaluates the policies of all files on every request. A nil Engine allows everything.
+type Engine struct {
+	rules []rule
+}
+
+// Load compiles the policies of the files, invalid expressions fail at startup.
+func Load(paths []string) (*Engine, error) {
+	e := &Engine{}
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var f file
+		if err := yaml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse policy file %s: %w", path, err)
+		}
+
+		for i, p := range f.Policies {
+			r := rule{
+				name:           p.Name,
+				operations:     p.Operations,
+				tenants:        p.Tenants,
+				configurations: p.Configurations,
+				message:        p.Message,
+			}
+			if r.name == "" {
+				r.name = fmt.Sprintf("%s#%d", path, i)
+			}
+			if p.Allow == "" && p.Deny == "" {
+				return nil, fmt.Errorf("policy %s: allow or deny is required", r.name)
+			}
+			for _, op := range p.Operations {
+				if op != OpRequest && op != OpIssue {
+					return nil, fmt.Errorf("policy %s: operation must be %s or %s, got %q", r.name, OpRequest, OpIssue, op)
+				}
+			}
+
+			if r.allow, err = compile(p.Allow); err != nil {
+				return nil, fmt.Errorf("policy %s: allow: %w", r.name, err)
+			}
+			if r.deny, err = compile(p.Deny); err != nil {
+				return nil, fmt.Errorf("policy %s: deny: %w", r.name, err)
+			}
+
+			e.rules = append(e.rules, r)
+		}
+	}
+
+	return e, nil
+}
+
+func compile(expr string) (cel.Program, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("%q must evaluate to a bool", expr)
+	}
+	return env.Program(ast)
+}
+
+func (r rule) applies(in Input) bool {
+	return (len(r.operations) == 0 || slices.Contains(r.operations, in.Operation)) &&
+		(len(r.tenants) == 0 || slices.Contains(r.tenants, in.Tenant)) &&
+		(len(r.configurations) == 0 || slices.Contains(r.configurations, in.Configuration))
+}
+
+// Evaluate returns an error wrapping ErrDenied for the first policy that denies the request. A policy
+// denies if its allow expression is false or its deny expression is true. Expressions that fail to
+// evaluate deny as well.
+func (e *Engine) Evaluate(in Input) error {
+	if e == nil {
+		return nil
+	}
+
+	payload := in.Payload
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	request := in.Request
+	if request == nil {
+		request = map[string]string{}
+	}
+	vars := map[string]interface{}{
+		"operation":     in.Operation,
+		"tenant":        in.Tenant,
+		"configuration": in.Configuration,
+		"payload":       payload,
+		"holder":        in.Holder,
+		"request":       request,
+	}
+
+	for _, r := range e.rules {
+		if !r.applies(in) {
+			continue
+		}
+
+		if denied, err := r.denies(vars); err != nil {
+			return fmt.Errorf("%w %s: %w", ErrDenied, r.name, err)
+		} else if denied {
+			if r.message != "" {
+				return fmt.Errorf("%w %s: %s", ErrDenied, r.name, r.message)
+			}
+			return fmt.Errorf("%w %s", ErrDenied, r.name)
+		}
+	}
+
+	return nil
+}
+
+func (r rule) denies(vars map[string]interface{}) (bool, error) {
+	if r.allow != nil {
+		out, _, err := r.allow.Eval(vars)
+		if err != nil {
+			return true, err
+		}
+		if out.Value() != true {
+			return true, nil
+		}
+	}
+
+	if r.deny != nil {
+		out, _, err := r.deny.Eval(vars)
+		if err != nil {
+			return true, err
+		}
+		if out.Value() == true {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,165 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicies(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: "policies:\n  - allow: tenant == \"t1\"\n    deny: holder == \"\"\n"},
+		{name: "syntax error", content: "policies:\n  - name: p1\n    allow: tenant ==\n", wantErr: "policy p1: allow"},
+		{name: "unknown variable", content: "policies:\n  - name: p1\n    deny: subject == \"x\"\n", wantErr: "policy p1: deny"},
+		{name: "no bool", content: "policies:\n  - name: p1\n    allow: tenant\n", wantErr: "must evaluate to a bool"},
+		{name: "no expression", content: "policies:\n  - name: p1\n", wantErr: "allow or deny is required"},
+		{name: "unknown operation", content: "policies:\n  - name: p1\n    operations: [revoke]\n    allow: \"true\"\n", wantErr: "operation must be"},
+		{name: "unnamed policy", content: "policies:\n  - allow: \"true\"\n  - allow: tenant\n", wantErr: "#1"},
+		{name: "invalid yaml", content: "policies: [", wantErr: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load([]string{writePolicies(t, tt.content)})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load([]string{filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	engine, err := Load([]string{writePolicies(t, `
+policies:
+  - name: tenant
+    configurations: [EmployeeCredential]
+    allow: tenant == "t1"
+    message: only t1 may issue EmployeeCredential
+  - name: both
+    configurations: [BothCredential]
+    allow: tenant == "t1"
+    deny: payload.blocked == true
+  - name: adult
+    operations: [request]
+    configurations: [AgeCredential]
+    allow: payload.age >= 18
+  - name: holder
+    operations: [issue]
+    deny: holder != "" && !holder.startsWith("did:jwk:")
+  - name: format
+    operations: [issue]
+    configurations: [SDJWTCredential]
+    allow: request.format == "vc+sd-jwt"
+`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   Input
+		// name of the policy that denies, empty if allowed
+		deniedBy string
+	}{
+		{name: "allowed", in: Input{Operation: OpRequest, Tenant: "t1", Configuration: "EmployeeCredential"}},
+		{name: "allow false", in: Input{Operation: OpRequest, Tenant: "t2", Configuration: "EmployeeCredential"}, deniedBy: "tenant"},
+		{name: "other configuration", in: Input{Operation: OpRequest, Tenant: "t2", Configuration: "OtherCredential"}},
+		{
+			name:     "deny wins over allow",
+			in:       Input{Operation: OpRequest, Tenant: "t1", Configuration: "BothCredential", Payload: map[string]interface{}{"blocked": true}},
+			deniedBy: "both",
+		},
+		{
+			name: "neither denies",
+			in:   Input{Operation: OpRequest, Tenant: "t1", Configuration: "BothCredential", Payload: map[string]interface{}{"blocked": false}},
+		},
+		{
+			name: "request payload",
+			in:   Input{Operation: OpRequest, Tenant: "t1", Configuration: "AgeCredential", Payload: map[string]interface{}{"age": 30}},
+		},
+		{
+			name:     "request payload denied",
+			in:       Input{Operation: OpRequest, Tenant: "t1", Configuration: "AgeCredential", Payload: map[string]interface{}{"age": 17}},
+			deniedBy: "adult",
+		},
+		{name: "missing variable", in: Input{Operation: OpRequest, Tenant: "t1", Configuration: "AgeCredential"}, deniedBy: "adult"},
+		{name: "request policy on issue", in: Input{Operation: OpIssue, Tenant: "t1", Configuration: "AgeCredential"}},
+		{name: "issue holder", in: Input{Operation: OpIssue, Tenant: "t1", Configuration: "AgeCredential", Holder: "did:jwk:abc"}},
+		{name: "issue holder denied", in: Input{Operation: OpIssue, Tenant: "t1", Configuration: "AgeCredential", Holder: "did:web:example.com"}, deniedBy: "holder"},
+		{name: "issue policy on request", in: Input{Operation: OpRequest, Tenant: "t1", Holder: "did:web:example.com"}},
+		{
+			name: "issue format",
+			in:   Input{Operation: OpIssue, Tenant: "t1", Configuration: "SDJWTCredential", Request: map[string]string{"format": "vc+sd-jwt"}},
+		},
+		{
+			name:     "issue format denied",
+			in:       Input{Operation: OpIssue, Tenant: "t1", Configuration: "SDJWTCredential", Request: map[string]string{"format": "jwt_vc_json"}},
+			deniedBy: "format",
+		},
+		{name: "issue without format", in: Input{Operation: OpIssue, Tenant: "t1", Configuration: "SDJWTCredential"}, deniedBy: "format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.Evaluate(tt.in)
+			if tt.deniedBy == "" {
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDenied) || !strings.Contains(err.Error(), ErrDenied.Error()+" "+tt.deniedBy) {
+				t.Errorf("Evaluate() error = %v, want denied by %s", err, tt.deniedBy)
+			}
+		})
+	}
+}
+
+func TestEvaluateFirstDenyingPolicy(t *testing.T) {
+	engine, err := Load([]string{
+		writePolicies(t, "policies:\n  - name: first\n    allow: tenant == \"t1\"\n    message: t1 only\n"),
+		writePolicies(t, "policies:\n  - name: second\n    deny: \"true\"\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.Evaluate(Input{Tenant: "t2"}); err == nil || err.Error() != "denied by policy first: t1 only" {
+		t.Errorf("Evaluate() error = %v, want the message of the first policy", err)
+	}
+	if err := engine.Evaluate(Input{Tenant: "t1"}); err == nil || !strings.Contains(err.Error(), "second") {
+		t.Errorf("Evaluate() error = %v, want denied by the second file", err)
+	}
+}
+
+func TestNilEngine(t *testing.T) {
+	var engine *Engine
+	if err := engine.Evaluate(Input{Operation: OpIssue}); err != nil {
+		t.Errorf("Evaluate() = %v", err)
+	}
+}
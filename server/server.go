@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// Serve answers HTTP requests on HTTP_ADDR until ctx is done. /isAlive is added to the given routes
+// for the readiness probe.
+func Serve(ctx context.Context, conf config.Config, mux *http.ServeMux) {
+	mux.HandleFunc("GET /isAlive", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	listen(ctx, conf, conf.Http.Addr, mux)
+}
+
+// ServeAdmin answers the administrative routes on HTTP_ADMIN_ADDR until ctx is done, to callers
+// authenticated with one of HTTP_ADMIN_TOKENS. Without tokens the routes are not served.
+func ServeAdmin(ctx context.Context, conf config.Config, mux *http.ServeMux) {
+	if len(conf.Http.AdminTokens) == 0 {
+		slog.Warn("administrative routes disabled, HTTP_ADMIN_TOKENS is empty")
+		return
+	}
+
+	listen(ctx, conf, conf.Http.AdminAddr, Authenticate(conf.Http.AdminTokens, mux))
+}
+
+func listen(ctx context.Context, conf config.Config, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		slog.Info("serving http", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server failed", "addr", addr, "error", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case <-stopped:
+		return
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("http server could not be shut down", "addr", addr, "error", err)
+	}
+	<-stopped
+}
+
+type callerKey struct{}
+
+// Authenticate passes requests carrying one of the tokens as bearer token on to next, with the name
+// of the token available through Caller. Others are answered with 401.
+func Authenticate(tokens map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok {
+			for name, t := range tokens {
+				if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, name)))
+					return
+				}
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// Caller returns the name of the caller authenticated by Authenticate, empty if there is none.
+func Caller(ctx context.Context) string {
+	name, _ := ctx.Value(callerKey{}).(string)
+	return name
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	tokens := map[string]string{"alice": "a-token", "bob": "b-token"}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantCaller string
+	}{
+		{name: "no header", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", header: "Bearer other", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic a-token", wantStatus: http.StatusUnauthorized},
+		{name: "empty token", header: "Bearer ", wantStatus: http.StatusUnauthorized},
+		{name: "first caller", header: "Bearer a-token", wantStatus: http.StatusOK, wantCaller: "alice"},
+		{name: "second caller", header: "Bearer b-token", wantStatus: http.StatusOK, wantCaller: "bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var caller string
+			h := Authenticate(tokens, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				caller = Caller(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/approvals", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if caller != tt.wantCaller {
+				t.Errorf("Caller() = %q, want %q", caller, tt.wantCaller)
+			}
+		})
+	}
+}
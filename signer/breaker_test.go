@@ -0,0 +1,57 @@
+package signer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+
+	// steps: a allows, s records a success, f a failure, w waits for the cooldown
+	tests := []struct {
+		name      string
+		threshold int
+		steps     string
+		want      []bool
+	}{
+		{name: "disabled", threshold: 0, steps: "ffffa", want: []bool{true}},
+		{name: "closed below threshold", threshold: 3, steps: "ffa", want: []bool{true}},
+		{name: "opens at threshold", threshold: 3, steps: "fffa", want: []bool{false}},
+		{name: "success resets failures", threshold: 3, steps: "ffsffa", want: []bool{true}},
+		{name: "single probe after cooldown", threshold: 2, steps: "ffwaa", want: []bool{true, false}},
+		{name: "successful probe closes", threshold: 2, steps: "ffwasaa", want: []bool{true, true, true}},
+		{name: "failed probe reopens", threshold: 2, steps: "ffwafa", want: []bool{true, false}},
+		{name: "probe after second cooldown", threshold: 2, steps: "ffwafwa", want: []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(tt.threshold, cooldown)
+
+			var got []bool
+			for _, step := range tt.steps {
+				switch step {
+				case 'a':
+					got = append(got, b.allow())
+				case 's':
+					b.success()
+				case 'f':
+					b.failure()
+				case 'w':
+					time.Sleep(cooldown + 5*time.Millisecond)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("allow() answered %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("allow() answered %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// ErrUnavailable is returned when the signer could not be reached after all retries or the circuit breaker is open.
+var ErrUnavailable = errors.New("signer service unavailable")
+
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("signer service returned %d: %s", e.StatusCode, e.Body)
+}
+
+type Client struct {
+	url     string
+	origin  string
+	conf    config.SignerConfig
+	http    *http.Client
+	tokens  *tokenSource
+	breaker *breaker
+}
+
+func New(conf config.Config) (*Client, error) {
+	tlsConf, err := tlsConfig(conf.Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = conf.Signer.MaxIdleConns
+	if tlsConf != nil {
+		transport.TLSClientConfig = tlsConf
+	}
+
+	c := &Client{
+		url:     conf.SignerUrl,
+		origin:  conf.Origin,
+		conf:    conf.Signer,
+		http:    &http.Client{Transport: transport},
+		breaker: newBreaker(conf.Signer.BreakerThreshold, conf.Signer.BreakerCooldown),
+	}
+
+	if conf.Signer.TokenUrl != "" {
+		c.tokens = &tokenSource{
+			http:         c.http,
+			tokenUrl:     conf.Signer.TokenUrl,
+			clientId:     conf.Signer.ClientId,
+			clientSecret: conf.Signer.ClientSecret,
+			scopes:       conf.Signer.Scopes,
+		}
+	}
+
+	return c, nil
+}
+
+// Sign posts body to the signer and returns the response body. Network errors and 5xx responses are
+// retried with jittered exponential backoff as long as ctx allows it, each attempt gets its share of
+// the time left. Timeouts count as failures of the signer, even if the deadline is the caller's.
+func (c *Client) Sign(ctx context.Context, body []byte) ([]byte, error) {
+	if !c.breaker.allow() {
+		return nil, fmt.Errorf("%w: circuit open", ErrUnavailable)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.conf.Retries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, backoff(c.conf.RetryBackoff, attempt)); err != nil {
+				break
+			}
+		}
+
+		b, retry, err := c.do(ctx, body, c.conf.Retries-attempt+1)
+		if err == nil {
+			c.breaker.success()
+			return b, nil
+		}
+
+		lastErr = err
+		if retry {
+			continue
+		}
+		switch {
+		case timedOut(err):
+			c.breaker.failure()
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		case ctx.Err() != nil:
+			// cancelled by the caller, the signer was neither healthy nor failing
+			c.breaker.release()
+			return nil, err
+		}
+		c.breaker.success()
+		return nil, err
+	}
+
+	c.breaker.failure()
+	return nil, fmt.Errorf("%w: %w", ErrUnavailable, lastErr)
+}
+
+func (c *Client) do(ctx context.Context, body []byte, attemptsLeft int) ([]byte, bool, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.attemptTimeout(ctx, attemptsLeft))
+	defer cancel()
+
+	r, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	r.Header.Add("Content-Type", "application/json")
+	r.Header.Add("x-origin", c.origin)
+
+	if c.tokens != nil {
+		token, err := c.tokens.Token(attemptCtx)
+		if err != nil {
+			return nil, ctx.Err() == nil, fmt.Errorf("signer token could not be obtained: %w", err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.http.Do(r)
+	if err != nil {
+		// the caller's deadline is gone, another attempt cannot succeed
+		if ctx.Err() != nil {
+			return nil, false, err
+		}
+		var netErr net.Error
+		return nil, errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, context.DeadlineExceeded), err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if res.StatusCode == http.StatusUnauthorized && c.tokens != nil {
+		// the token may have been revoked before its expiry, fetch a new one on the next attempt
+		c.tokens.Invalidate()
+		return nil, true, &StatusError{StatusCode: res.StatusCode, Body: string(b)}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, res.StatusCode >= 500, &StatusError{StatusCode: res.StatusCode, Body: string(b)}
+	}
+
+	return b, false, nil
+}
+
+// attemptTimeout is SIGNER_TIMEOUT, capped so that the attempts left share the time until the
+// deadline of ctx.
+func (c *Client) attemptTimeout(ctx context.Context, attemptsLeft int) time.Duration {
+	timeout := c.conf.Timeout
+	if deadline, ok := ctx.Deadline(); ok && attemptsLeft > 0 {
+		if share := time.Until(deadline) / time.Duration(attemptsLeft); share < timeout {
+			timeout = share
+		}
+	}
+	return timeout
+}
+
+// timedOut reports whether the signer did not answer in time.
+func timedOut(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// hangingSigner accepts requests but never answers them before the client gives up.
+func hangingSigner(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		srv.Close()
+	})
+	return srv, &requests
+}
+
+func newTestClient(t *testing.T, url string, retries int, threshold int) *Client {
+	t.Helper()
+
+	conf := config.Default()
+	conf.SignerUrl = url
+	conf.Signer.Retries = retries
+	conf.Signer.RetryBackoff = time.Millisecond
+	conf.Signer.BreakerThreshold = threshold
+	conf.Signer.BreakerCooldown = time.Minute
+	c, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSignHangingSigner(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		threshold    int
+		calls        int
+		wantRequests int32
+		wantOpen     bool
+	}{
+		{name: "retries share the deadline", retries: 2, threshold: 0, calls: 1, wantRequests: 3},
+		{name: "timeouts open the breaker", retries: 0, threshold: 2, calls: 2, wantRequests: 2, wantOpen: true},
+		{name: "below threshold", retries: 0, threshold: 3, calls: 2, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := hangingSigner(t)
+			c := newTestClient(t, srv.URL, tt.retries, tt.threshold)
+
+			for i := 0; i < tt.calls; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+				_, err := c.Sign(ctx, []byte(`{}`))
+				cancel()
+				if !errors.Is(err, ErrUnavailable) {
+					t.Fatalf("Sign() error = %v, want %v", err, ErrUnavailable)
+				}
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("%d requests reached the signer, want %d", got, tt.wantRequests)
+			}
+			if open := !c.breaker.allow(); open != tt.wantOpen {
+				t.Errorf("breaker open = %v, want %v", open, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestSignClientErrorKeepsBreakerClosed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad credential", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL, 2, 1)
+
+	var status *StatusError
+	if _, err := c.Sign(context.Background(), []byte(`{}`)); !errors.As(err, &status) || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Sign() error = %v, want the status error", err)
+	}
+	if !c.breaker.allow() {
+		t.Error("breaker opened on a client error")
+	}
+}
@@ -0,0 +1,48 @@
+package signer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+)
+
+// tlsConfig builds the client TLS settings for the signer. It returns nil if neither a client certificate
+// nor a CA bundle is configured, so that the system defaults apply.
+func tlsConfig(conf config.SignerConfig) (*tls.Config, error) {
+	if conf.CertFile == "" && conf.KeyFile == "" && conf.CAFile == "" {
+		return nil, nil
+	}
+
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("signer client certificate needs both SIGNER_CERT_FILE and SIGNER_KEY_FILE")
+		}
+
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("signer client certificate could not be loaded: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("signer CA bundle could not be read: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("signer CA bundle %s contains no certificates", conf.CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	return c, nil
+}
@@ -0,0 +1,105 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// expirySkew renews tokens a little before the authorization server considers them expired.
+	expirySkew = 30 * time.Second
+	// defaultLifetime applies to tokens without expires_in, a token revoked earlier is renewed after the 401.
+	defaultLifetime = 5 * time.Minute
+)
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// tokenSource fetches OAuth2 client credentials tokens and caches them until shortly before expiry.
+type tokenSource struct {
+	mu           sync.Mutex
+	http         *http.Client
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	scopes       []string
+	token        string
+	expiry       time.Time
+}
+
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expiry) {
+		return s.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(url.QueryEscape(s.clientId), url.QueryEscape(s.clientSecret))
+
+	res, err := s.http.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s", res.StatusCode, string(b))
+	}
+
+	var t tokenResponse
+	if err := json.Unmarshal(b, &t); err != nil {
+		return "", err
+	}
+
+	if t.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint returned no access token")
+	}
+
+	s.token = t.AccessToken
+	s.expiry = time.Now().Add(cacheFor(t.ExpiresIn))
+	return s.token, nil
+}
+
+// cacheFor returns how long a token with the lifetime expires_in is used. Short-lived tokens are kept at
+// least for half their lifetime, so that the skew does not renew them for every signature.
+func cacheFor(expiresIn int64) time.Duration {
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultLifetime
+	}
+	return max(lifetime-expirySkew, lifetime/2)
+}
+
+// Invalidate drops the cached token, e.g. after the signer rejected it.
+func (s *tokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = ""
+}
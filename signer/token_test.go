@@ -0,0 +1,28 @@
+package signer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn int64
+		want      time.Duration
+	}{
+		{name: "renewed before expiry", expiresIn: 3600, want: time.Hour - expirySkew},
+		{name: "short lifetime kept for half of it", expiresIn: 20, want: 10 * time.Second},
+		{name: "lifetime of the skew", expiresIn: 30, want: 15 * time.Second},
+		{name: "missing expires_in", expiresIn: 0, want: defaultLifetime - expirySkew},
+		{name: "negative expires_in", expiresIn: -5, want: defaultLifetime - expirySkew},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheFor(tt.expiresIn); got != tt.want {
+				t.Errorf("cacheFor(%d) = %s, want %s", tt.expiresIn, got, tt.want)
+			}
+		})
+	}
+}
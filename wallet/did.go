@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+const didJwkPrefix = "did:jwk:"
+
+var ErrUnknownKey = errors.New("verification key could not be resolved")
+
+// KeyResolver returns the public key of a trusted issuer named by a kid or verificationMethod. A
+// did:jwk carries its own key and is only trusted if a resolver like TrustDids returns it.
+type KeyResolver func(kid string) (jwk.Key, error)
+
+// Holder is the key the simulated wallet binds its credentials to, identified by did:jwk.
+type Holder struct {
+	key jwk.Key
+	Did string
+}
+
+// NewHolder generates a P-256 holder key.
+func NewHolder() (*Holder, error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	return HolderFor(key)
+}
+
+func newKey() (jwk.Key, error) {
+	raw, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return jwk.FromRaw(raw)
+}
+
+// HolderFor uses an existing private key as holder key.
+func HolderFor(key jwk.Key) (*Holder, error) {
+	did, err := DidJwk(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Holder{key: key, Did: did}, nil
+}
+
+// Kid names the key of the holder in proofs.
+func (h *Holder) Kid() string {
+	return h.Did + "#0"
+}
+
+// PublicKey is the public key of the holder.
+func (h *Holder) PublicKey() (jwk.Key, error) {
+	return h.key.PublicKey()
+}
+
+// DidJwk returns the did:jwk of the public part of key.
+func DidJwk(key jwk.Key) (string, error) {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(pub)
+	if err != nil {
+		return "", err
+	}
+	return didJwkPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ResolveDidJwk returns the public key of a did:jwk, with or without the #0 fragment.
+func ResolveDidJwk(did string) (jwk.Key, error) {
+	id, fragment, _ := strings.Cut(did, "#")
+	encoded, ok := strings.CutPrefix(id, didJwkPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is no did:jwk", ErrUnknownKey, did)
+	}
+	if fragment != "" && fragment != "0" {
+		return nil, fmt.Errorf("%w: did:jwk has no key #%s", ErrUnknownKey, fragment)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownKey, err)
+	}
+	key, err := jwk.ParseKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnknownKey, err)
+	}
+	if _, private := key.(interface{ D() []byte }); private {
+		return nil, fmt.Errorf("%w: did:jwk carries a private key", ErrUnknownKey)
+	}
+	return key, nil
+}
+
+// resolve asks the resolver for the key of kid, without a resolver no issuer is trusted.
+func (r KeyResolver) resolve(kid string) (jwk.Key, error) {
+	if r == nil || kid == "" {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	key, err := r(kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrUnknownKey, kid, err)
+	}
+	return key, nil
+}
+
+// KeySet resolves kids from a JWK set, e.g. the keys of a signer. Keys are matched by their kid
+// or, for verification methods like did:web:issuer#key-1, by the fragment.
+func KeySet(set jwk.Set) KeyResolver {
+	return func(kid string) (jwk.Key, error) {
+		_, fragment, _ := strings.Cut(kid, "#")
+		for _, id := range []string{kid, fragment} {
+			if key, ok := set.LookupKeyID(id); ok && id != "" {
+				return key.PublicKey()
+			}
+		}
+		return nil, errors.New("not in key set")
+	}
+}
+
+// TrustDids resolves the keys of the given did:jwk issuers, with or without the #0 fragment.
+func TrustDids(dids ...string) KeyResolver {
+	return func(kid string) (jwk.Key, error) {
+		id, _, _ := strings.Cut(kid, "#")
+		for _, did := range dids {
+			if trusted, _, _ := strings.Cut(did, "#"); trusted == id {
+				return ResolveDidJwk(kid)
+			}
+		}
+		return nil, errors.New("not a trusted did")
+	}
+}
+
+// AnyOf resolves a kid with the first of the resolvers that knows it.
+func AnyOf(resolvers ...KeyResolver) KeyResolver {
+	return func(kid string) (jwk.Key, error) {
+		errs := make([]error, 0, len(resolvers))
+		for _, r := range resolvers {
+			if r == nil {
+				continue
+			}
+			key, err := r(kid)
+			if err == nil {
+				return key, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+)
+
+// Cryptosuites of DataIntegrityProof that are verified. Suites canonicalizing with RDF, like
+// JsonWebSignature2020 or ecdsa-rdfc-2019, are reported with ErrUnsupportedProof.
+const (
+	CryptosuiteEcdsaJcs = "ecdsa-jcs-2019"
+	CryptosuiteEddsaJcs = "eddsa-jcs-2022"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid credential signature")
+	ErrUnsupportedProof = errors.New("unsupported proof")
+)
+
+// DataIntegrityHash returns the data a jcs cryptosuite signs: the SHA-256 of the canonical proof
+// configuration followed by that of the canonical document. The proof of the document and the
+// proofValue of the configuration are left out, the configuration gets the @context of the document.
+func DataIntegrityHash(document map[string]any, proof map[string]any) ([]byte, error) {
+	unsecured := maps.Clone(document)
+	delete(unsecured, "proof")
+
+	config := maps.Clone(proof)
+	delete(config, "proofValue")
+	if c, ok := document["@context"]; ok {
+		config["@context"] = c
+	}
+
+	c, err := Canonicalize(config)
+	if err != nil {
+		return nil, err
+	}
+	d, err := Canonicalize(unsecured)
+	if err != nil {
+		return nil, err
+	}
+
+	configHash := sha256.Sum256(c)
+	documentHash := sha256.Sum256(d)
+	return append(configHash[:], documentHash[:]...), nil
+}
+
+// verifyLdp verifies every proof of an ldp_vc credential.
+func verifyLdp(document map[string]any, keys KeyResolver) error {
+	var proofs []map[string]any
+	switch p := document["proof"].(type) {
+	case map[string]any:
+		proofs = append(proofs, p)
+	case []any:
+		for _, e := range p {
+			m, ok := e.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: proof must be an object", ErrInvalidSignature)
+			}
+			proofs = append(proofs, m)
+		}
+	}
+	if len(proofs) == 0 {
+		return fmt.Errorf("%w: credential has no proof", ErrInvalidSignature)
+	}
+
+	for _, p := range proofs {
+		if err := verifyProof(document, p, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyProof(document map[string]any, proof map[string]any, keys KeyResolver) error {
+	proofType, _ := proof["type"].(string)
+	suite, _ := proof["cryptosuite"].(string)
+	if proofType != "DataIntegrityProof" || (suite != CryptosuiteEcdsaJcs && suite != CryptosuiteEddsaJcs) {
+		return fmt.Errorf("%w: %s %s", ErrUnsupportedProof, proofType, suite)
+	}
+	if purpose, _ := proof["proofPurpose"].(string); purpose != "assertionMethod" {
+		return fmt.Errorf("%w: proofPurpose must be assertionMethod, got %q", ErrInvalidSignature, purpose)
+	}
+
+	value, _ := proof["proofValue"].(string)
+	signature, err := MultibaseDecode(value)
+	if err != nil {
+		return fmt.Errorf("%w: proofValue: %w", ErrInvalidSignature, err)
+	}
+
+	method, _ := proof["verificationMethod"].(string)
+	key, err := keys.resolve(method)
+	if err != nil {
+		return err
+	}
+	var raw any
+	if err := key.Raw(&raw); err != nil {
+		return err
+	}
+
+	data, err := DataIntegrityHash(document, proof)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch suite {
+	case CryptosuiteEcdsaJcs:
+		pub, ok := raw.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != 256 || len(signature) != 64 {
+			return fmt.Errorf("%w: %s expects a P-256 key and signature", ErrInvalidSignature, suite)
+		}
+		digest := sha256.Sum256(data)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(pub, digest[:], r, s)
+	case CryptosuiteEddsaJcs:
+		pub, ok := raw.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s expects an Ed25519 key", ErrInvalidSignature, suite)
+		}
+		valid = ed25519.Verify(pub, data, signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: %s proof of %s does not match", ErrInvalidSignature, suite, method)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/eclipse-xfsc/oid4-vci-vp-library/model/credential"
+)
+
+var ErrInvalidOffer = errors.New("invalid credential offer")
+
+// ResolveOffer returns the parameters of a credential offer passed by value in credential_offer or
+// by reference in credential_offer_uri, which is fetched with client.
+func ResolveOffer(ctx context.Context, client *http.Client, offer string) (credential.CredentialOfferParameters, error) {
+	var params credential.CredentialOfferParameters
+
+	u, err := url.Parse(offer)
+	if err != nil {
+		return params, fmt.Errorf("%w: %w", ErrInvalidOffer, err)
+	}
+
+	uri := u.Query().Get("credential_offer_uri")
+	if uri == "" {
+		params, err = credential.CredentialOffer{CredentialOffer: offer}.GetOfferParameters()
+		if err != nil {
+			return params, fmt.Errorf("%w: %w", ErrInvalidOffer, err)
+		}
+		return params, nil
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return params, fmt.Errorf("%w: %w", ErrInvalidOffer, err)
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return params, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return params, fmt.Errorf("%w: %s answered %s", ErrInvalidOffer, uri, res.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return params, err
+	}
+	if err := json.Unmarshal(b, &params); err != nil {
+		return params, fmt.Errorf("%w: %s: %w", ErrInvalidOffer, uri, err)
+	}
+	return params, nil
+}
+
+// PreAuthorizedCode returns the pre-authorized code of the offer.
+func PreAuthorizedCode(params credential.CredentialOfferParameters) (string, error) {
+	g := params.Grants.PreAuthorizedCode
+	if g == nil || g.PreAuthorizationCode == "" {
+		return "", fmt.Errorf("%w: no pre-authorized code", ErrInvalidOffer)
+	}
+	return g.PreAuthorizationCode, nil
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// ProofType is the typ of OpenID4VCI key proofs.
+const ProofType = "openid4vci-proof+jwt"
+
+var ErrInvalidProof = errors.New("invalid key proof")
+
+// Proof returns the key proof JWT the wallet sends with its credential request to the issuer.
+func (h *Holder) Proof(audience string, nonce string) (string, error) {
+	b := jwt.NewBuilder().Audience([]string{audience}).IssuedAt(time.Now())
+	if nonce != "" {
+		b = b.Claim("nonce", nonce)
+	}
+	tok, err := b.Build()
+	if err != nil {
+		return "", err
+	}
+
+	headers := jws.NewHeaders()
+	for k, v := range map[string]any{jws.TypeKey: ProofType, jws.KeyIDKey: h.Kid()} {
+		if err := headers.Set(k, v); err != nil {
+			return "", err
+		}
+	}
+
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.ES256, h.key, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+// ProofHolder checks a key proof like the issuer service does before it calls .issue and returns
+// the did:jwk of the holder.
+func ProofHolder(proof string, audience string, maxAge time.Duration) (string, error) {
+	msg, err := jws.Parse([]byte(proof))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidProof, err)
+	}
+	if len(msg.Signatures()) != 1 {
+		return "", fmt.Errorf("%w: expected one signature", ErrInvalidProof)
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	if headers.Type() != ProofType {
+		return "", fmt.Errorf("%w: typ must be %s, got %q", ErrInvalidProof, ProofType, headers.Type())
+	}
+
+	key, err := ResolveDidJwk(headers.KeyID())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidProof, err)
+	}
+	tok, err := jwt.Parse([]byte(proof),
+		jwt.WithKey(headers.Algorithm(), key),
+		jwt.WithAudience(audience),
+		jwt.WithAcceptableSkew(time.Minute),
+	)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidProof, err)
+	}
+	if tok.IssuedAt().IsZero() || time.Since(tok.IssuedAt()) > maxAge {
+		return "", fmt.Errorf("%w: iat is missing or older than %s", ErrInvalidProof, maxAge)
+	}
+
+	did, _, _ := strings.Cut(headers.KeyID(), "#")
+	return did, nil
+}
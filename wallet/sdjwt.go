@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+var ErrInvalidDisclosure = errors.New("invalid disclosure")
+
+// Disclosure is one selectively disclosed claim of an SD-JWT. Name is empty for array elements.
+type Disclosure struct {
+	Name  string `json:"name,omitempty"`
+	Value any    `json:"value"`
+}
+
+type disclosure struct {
+	Disclosure
+	digest string
+	used   bool
+}
+
+// verifySdJwt verifies the issuer signature of an SD-JWT and returns its claims with every
+// disclosure in place. Disclosures that no digest of the credential refers to are rejected.
+func verifySdJwt(sdJwt string, keys KeyResolver) (map[string]any, []Disclosure, error) {
+	parts := strings.Split(sdJwt, "~")
+	if len(parts) < 2 {
+		return nil, nil, fmt.Errorf("%w: credential is no SD-JWT", ErrInvalidSignature)
+	}
+	issuerJwt := parts[0]
+
+	payload, err := verifyIssuerJwt(issuerJwt, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if alg, ok := claims["_sd_alg"]; ok && alg != "sha-256" {
+		return nil, nil, fmt.Errorf("%w: _sd_alg %v is not supported", ErrInvalidDisclosure, alg)
+	}
+	delete(claims, "_sd_alg")
+
+	// the last part is the key binding JWT of a presentation, empty when issued
+	encoded := parts[1 : len(parts)-1]
+	byDigest := make(map[string]*disclosure, len(encoded))
+	list := make([]*disclosure, 0, len(encoded))
+	for _, e := range encoded {
+		d, err := parseDisclosure(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		if byDigest[d.digest] != nil {
+			return nil, nil, fmt.Errorf("%w: disclosure %s is repeated", ErrInvalidDisclosure, d.digest)
+		}
+		byDigest[d.digest] = d
+		list = append(list, d)
+	}
+
+	disclosed, err := disclose(claims, byDigest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	disclosures := make([]Disclosure, 0, len(list))
+	for _, d := range list {
+		if !d.used {
+			return nil, nil, fmt.Errorf("%w: disclosure %s is not referenced by the credential", ErrInvalidDisclosure, d.digest)
+		}
+		disclosures = append(disclosures, d.Disclosure)
+	}
+	return disclosed.(map[string]any), disclosures, nil
+}
+
+// verifyIssuerJwt checks the signature with the issuer key the resolver returns for the kid. A jwk
+// header is only accepted if it is that key, the token cannot bring its own.
+func verifyIssuerJwt(issuerJwt string, keys KeyResolver) ([]byte, error) {
+	msg, err := jws.Parse([]byte(issuerJwt))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, fmt.Errorf("%w: expected one signature", ErrInvalidSignature)
+	}
+	headers := msg.Signatures()[0].ProtectedHeaders()
+
+	key, err := keys.resolve(headers.KeyID())
+	if err != nil {
+		return nil, err
+	}
+	if embedded := headers.JWK(); embedded != nil {
+		if same, err := sameKey(embedded, key); err != nil || !same {
+			return nil, fmt.Errorf("%w: the jwk header is not the key of %q", ErrUnknownKey, headers.KeyID())
+		}
+	}
+
+	payload, err := jws.Verify([]byte(issuerJwt), jws.WithKey(headers.Algorithm(), key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return payload, nil
+}
+
+// sameKey compares the public keys by their thumbprint.
+func sameKey(a jwk.Key, b jwk.Key) (bool, error) {
+	ta, err := a.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return false, err
+	}
+	tb, err := b.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ta, tb), nil
+}
+
+func parseDisclosure(encoded string) (*disclosure, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDisclosure, err)
+	}
+
+	var fields []any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDisclosure, err)
+	}
+
+	digest := sha256.Sum256([]byte(encoded))
+	d := &disclosure{digest: base64.RawURLEncoding.EncodeToString(digest[:])}
+	switch len(fields) {
+	case 2:
+		d.Value = fields[1]
+	case 3:
+		name, ok := fields[1].(string)
+		if !ok || name == "_sd" || name == "..." {
+			return nil, fmt.Errorf("%w: invalid claim name %v", ErrInvalidDisclosure, fields[1])
+		}
+		d.Name, d.Value = name, fields[2]
+	default:
+		return nil, fmt.Errorf("%w: expected 2 or 3 elements, got %d", ErrInvalidDisclosure, len(fields))
+	}
+	return d, nil
+}
+
+// disclose replaces the digests in v by the disclosed claims, undisclosed digests are dropped.
+func disclose(v any, byDigest map[string]*disclosure) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if k == "_sd" {
+				continue
+			}
+			e, err := disclose(e, byDigest)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = e
+		}
+
+		digests, _ := v["_sd"].([]any)
+		for _, digest := range digests {
+			d, err := use(digest, byDigest, true)
+			if err != nil {
+				return nil, err
+			}
+			if d == nil {
+				continue
+			}
+			if _, exists := out[d.Name]; exists {
+				return nil, fmt.Errorf("%w: claim %s is disclosed twice", ErrInvalidDisclosure, d.Name)
+			}
+			value, err := disclose(d.Value, byDigest)
+			if err != nil {
+				return nil, err
+			}
+			out[d.Name] = value
+		}
+		return out, nil
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, e := range v {
+			if m, ok := e.(map[string]any); ok && len(m) == 1 && m["..."] != nil {
+				d, err := use(m["..."], byDigest, false)
+				if err != nil {
+					return nil, err
+				}
+				if d == nil {
+					continue
+				}
+				e = d.Value
+			}
+			e, err := disclose(e, byDigest)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, e)
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// use marks the disclosure of a digest as referenced, it returns nil for digests without disclosure.
+func use(digest any, byDigest map[string]*disclosure, named bool) (*disclosure, error) {
+	s, ok := digest.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: digest must be a string", ErrInvalidDisclosure)
+	}
+	d := byDigest[s]
+	if d == nil {
+		return nil, nil
+	}
+	if d.used {
+		return nil, fmt.Errorf("%w: digest %s is referenced twice", ErrInvalidDisclosure, s)
+	}
+	if named != (d.Name != "") {
+		return nil, fmt.Errorf("%w: disclosure %s does not fit its position", ErrInvalidDisclosure, s)
+	}
+	d.used = true
+	return d, nil
+}
@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+)
+
+// issuerJwt signs the payload with signer, naming kid and, if given, embedding jwk in the header.
+func issuerJwt(t *testing.T, signer *Holder, kid string, embedded jwk.Key, payload map[string]any) string {
+	t.Helper()
+
+	headers := jws.NewHeaders()
+	if kid != "" {
+		_ = headers.Set(jws.KeyIDKey, kid)
+	}
+	if embedded != nil {
+		_ = headers.Set(jws.JWKKey, embedded)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jws.Sign(b, jws.WithKey(jwa.ES256, signer.key, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func TestVerifyIssuerKey(t *testing.T) {
+	issuer, _ := NewHolder()
+	attacker, _ := NewHolder()
+	issuerKey, _ := issuer.PublicKey()
+	attackerKey, _ := attacker.PublicKey()
+	trusted := TrustDids(issuer.Did)
+
+	tests := []struct {
+		name     string
+		signer   *Holder
+		kid      string
+		embedded jwk.Key
+		keys     KeyResolver
+		wantErr  error
+	}{
+		{name: "trusted did:jwk", signer: issuer, kid: issuer.Kid(), keys: trusted},
+		{name: "trusted did:jwk with its jwk", signer: issuer, kid: issuer.Kid(), embedded: issuerKey, keys: trusted},
+		{name: "key set", signer: issuer, kid: "key-1", keys: KeySet(keySet(t, issuerKey, "key-1"))},
+		{name: "no resolver", signer: issuer, kid: issuer.Kid(), wantErr: ErrUnknownKey},
+		{name: "untrusted did:jwk", signer: attacker, kid: attacker.Kid(), keys: trusted, wantErr: ErrUnknownKey},
+		{name: "embedded jwk only", signer: attacker, embedded: attackerKey, keys: trusted, wantErr: ErrUnknownKey},
+		{name: "embedded jwk of another key", signer: attacker, kid: issuer.Kid(), embedded: attackerKey, keys: trusted, wantErr: ErrUnknownKey},
+		{name: "signed by another key", signer: attacker, kid: issuer.Kid(), keys: trusted, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdJwt := issuerJwt(t, tt.signer, tt.kid, tt.embedded, map[string]any{"iss": "x", "given_name": "Jane"}) + "~"
+
+			claims, _, err := verifySdJwt(sdJwt, tt.keys)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifySdJwt() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims["given_name"] != "Jane" {
+				t.Errorf("claims = %v", claims)
+			}
+		})
+	}
+}
+
+func keySet(t *testing.T, key jwk.Key, kid string) jwk.Set {
+	t.Helper()
+
+	key, err := key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = key.Set(jwk.KeyIDKey, kid)
+	set := jwk.NewSet()
+	_ = set.AddKey(key)
+	return set
+}
+
+func TestSdJwtDisclosures(t *testing.T) {
+	issuer, _ := NewHolder()
+	disclosure := func(parts ...any) (string, string) {
+		b, _ := json.Marshal(parts)
+		encoded := base64.RawURLEncoding.EncodeToString(b)
+		digest := sha256.Sum256([]byte(encoded))
+		return encoded, base64.RawURLEncoding.EncodeToString(digest[:])
+	}
+
+	given, givenDigest := disclosure("s1", "given_name", "Jane")
+	_, familyDigest := disclosure("s2", "family_name", "Doe")
+	unknown, _ := disclosure("s3", "x", "y")
+	signed := issuerJwt(t, issuer, issuer.Kid(), nil, map[string]any{"_sd_alg": "sha-256", "_sd": []any{givenDigest, familyDigest}})
+
+	tests := []struct {
+		name    string
+		sdJwt   string
+		wantErr error
+	}{
+		{name: "disclosed", sdJwt: signed + "~" + given + "~"},
+		{name: "unreferenced disclosure", sdJwt: signed + "~" + unknown + "~", wantErr: ErrInvalidDisclosure},
+		{name: "repeated disclosure", sdJwt: signed + "~" + given + "~" + given + "~", wantErr: ErrInvalidDisclosure},
+		{name: "changed signature", sdJwt: signed + "x~", wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, _, err := verifySdJwt(tt.sdJwt, TrustDids(issuer.Did))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifySdJwt() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (claims["given_name"] != "Jane" || claims["family_name"] != nil) {
+				t.Errorf("claims = %v", claims)
+			}
+		})
+	}
+}
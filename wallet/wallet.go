@@ -0,0 +1,346 @@
+// Package wallet simulates the wallet side of a pre-authorized issuance against the module: it
+// resolves an offer, proves possession of a did:jwk holder key, picks the credential up on .issue
+// like the issuer service does and verifies the signature, disclosures and holder binding of the
+// credential. It backs "issuerctl wallet redeem" and integration tests.
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	cloudeventprovider "github.com/eclipse-xfsc/cloud-event-provider"
+	"github.com/eclipse-xfsc/nats-message-library/common"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/config"
+	"github.com/eclipse-xfsc/oid4-vci-issuer-dummycontentsigner/metadata"
+	issumsg "github.com/eclipse-xfsc/oid4-vci-issuer-service/pkg/messaging"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// proofMaxAge bounds the age of key proofs, as the issuer service checks it.
+const proofMaxAge = 5 * time.Minute
+
+var (
+	ErrHolderMismatch    = errors.New("credential is not bound to the holder")
+	ErrUnsupportedFormat = errors.New("unsupported credential format")
+	ErrDecryption        = errors.New("credential response could not be decrypted")
+)
+
+// ReplyError is the error the module answered the issue request with.
+type ReplyError struct {
+	Reply *common.Error
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.Reply.Id, e.Reply.Status, e.Reply.Msg)
+}
+
+type Config struct {
+	Nats          cloudeventprovider.NatsConfig
+	SubjectPrefix string
+	TenantId      string
+	// resolves the keys of the trusted issuers, credentials of other issuers fail verification
+	Keys KeyResolver
+	// requests the credential response encrypted to a key of the wallet
+	Encrypt bool
+	// fetches offers passed by reference, http.DefaultClient if nil
+	HttpClient *http.Client
+}
+
+// Credential is a credential picked up and verified by the wallet.
+type Credential struct {
+	ConfigurationId string `json:"configuration_id"`
+	Format          string `json:"format"`
+	NotificationId  string `json:"notification_id,omitempty"`
+	Holder          string `json:"holder"`
+	// the credential as issued: the ldp_vc document or the SD-JWT
+	Credential any `json:"credential"`
+	// the credential subject of an ldp_vc, the claims of an SD-JWT with its disclosures in place
+	Claims      map[string]any `json:"claims,omitempty"`
+	Disclosures []Disclosure   `json:"disclosures,omitempty"`
+}
+
+// Wallet redeems offers with a holder key of its own.
+type Wallet struct {
+	conf   Config
+	holder *Holder
+
+	mu      sync.Mutex
+	clients map[string]*cloudeventprovider.CloudEventProviderClient
+}
+
+// New returns a Wallet with a fresh holder key.
+func New(conf Config) (*Wallet, error) {
+	holder, err := NewHolder()
+	if err != nil {
+		return nil, err
+	}
+	return NewWithHolder(conf, holder), nil
+}
+
+// NewWithHolder returns a Wallet using the given holder key.
+func NewWithHolder(conf Config, holder *Holder) *Wallet {
+	if conf.HttpClient == nil {
+		conf.HttpClient = http.DefaultClient
+	}
+	return &Wallet{
+		conf:    conf,
+		holder:  holder,
+		clients: make(map[string]*cloudeventprovider.CloudEventProviderClient),
+	}
+}
+
+func (w *Wallet) Holder() *Holder {
+	return w.holder
+}
+
+// issueRequest is the issue request with the response encryption parameters of the module.
+type issueRequest struct {
+	issumsg.IssuanceModuleReq
+	CredentialResponseEncryption *responseEncryption `json:"credential_response_encryption,omitempty"`
+}
+
+type responseEncryption struct {
+	Jwk jwk.Key `json:"jwk"`
+	Alg string  `json:"alg"`
+	Enc string  `json:"enc"`
+}
+
+type issueReply struct {
+	issumsg.IssuanceModuleRep
+	NotificationId string `json:"notification_id,omitempty"`
+	Encrypted      bool   `json:"encrypted,omitempty"`
+}
+
+// Redeem picks up the credential of the offer and verifies it. The offer names the configuration
+// whose .issue subject is called; offers with several configurations are redeemed for the first,
+// as the pre-authorized code belongs to one credential. A credential that fails verification is
+// returned together with the error.
+func (w *Wallet) Redeem(ctx context.Context, offer string) (*Credential, error) {
+	params, err := ResolveOffer(ctx, w.conf.HttpClient, offer)
+	if err != nil {
+		return nil, err
+	}
+	code, err := PreAuthorizedCode(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Credentials) == 0 {
+		return nil, fmt.Errorf("%w: no credential configuration", ErrInvalidOffer)
+	}
+	configurationId := params.Credentials[0]
+
+	proof, err := w.holder.Proof(params.CredentialIssuer, "")
+	if err != nil {
+		return nil, err
+	}
+	holder, err := ProofHolder(proof, params.CredentialIssuer, proofMaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	req := issueRequest{IssuanceModuleReq: issumsg.IssuanceModuleReq{
+		Request: common.Request{
+			TenantId:  w.conf.TenantId,
+			RequestId: uuid.NewString(),
+		},
+		Code:   code,
+		Holder: holder,
+	}}
+
+	var decryptionKey jwk.Key
+	if w.conf.Encrypt {
+		if decryptionKey, req.CredentialResponseEncryption, err = encryptionParameters(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := w.issue(ctx, configurationId, req)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Error != nil {
+		return nil, &ReplyError{reply.Error}
+	}
+
+	c := &Credential{
+		ConfigurationId: configurationId,
+		Format:          reply.Format,
+		NotificationId:  reply.NotificationId,
+		Holder:          holder,
+		Credential:      reply.Credential,
+	}
+	if reply.Encrypted {
+		if err := decrypt(c, decryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, w.verify(c)
+}
+
+func (w *Wallet) issue(ctx context.Context, configurationId string, req issueRequest) (issueReply, error) {
+	var reply issueReply
+
+	subject, err := metadata.Subject(config.Config{SubjectPrefix: w.conf.SubjectPrefix}, configurationId)
+	if err != nil {
+		return reply, err
+	}
+	client, err := w.client(subject + ".issue")
+	if err != nil {
+		return reply, err
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return reply, err
+	}
+	ev, err := cloudeventprovider.NewEvent("wallet-simulator", "issue", b)
+	if err != nil {
+		return reply, err
+	}
+
+	rep, err := client.RequestCtx(ctx, ev)
+	if err != nil {
+		return reply, err
+	}
+	if rep == nil {
+		return reply, errors.New("no reply from " + subject + ".issue")
+	}
+	err = json.Unmarshal(rep.Data(), &reply)
+	return reply, err
+}
+
+func (w *Wallet) client(subject string) (*cloudeventprovider.CloudEventProviderClient, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if c, ok := w.clients[subject]; ok {
+		return c, nil
+	}
+
+	c, err := cloudeventprovider.New(
+		cloudeventprovider.Config{Protocol: cloudeventprovider.ProtocolTypeNats, Settings: w.conf.Nats},
+		cloudeventprovider.ConnectionTypeReq,
+		subject,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	w.clients[subject] = c
+	return c, nil
+}
+
+func (w *Wallet) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for subject, c := range w.clients {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(w.clients, subject)
+	}
+	return err
+}
+
+// verify checks the signature of the credential and that it is bound to the holder, if it names one.
+func (w *Wallet) verify(c *Credential) error {
+	switch c.Format {
+	case "ldp_vc":
+		document, ok := c.Credential.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: ldp_vc credential is no JSON object", ErrInvalidSignature)
+		}
+		if err := verifyLdp(document, w.conf.Keys); err != nil {
+			return err
+		}
+		c.Claims, _ = document["credentialSubject"].(map[string]any)
+		return w.bound(document)
+	case "vc+sd-jwt", "dc+sd-jwt":
+		sdJwt, ok := c.Credential.(string)
+		if !ok {
+			return fmt.Errorf("%w: SD-JWT credential is no string", ErrInvalidSignature)
+		}
+		claims, disclosures, err := verifySdJwt(sdJwt, w.conf.Keys)
+		if err != nil {
+			return err
+		}
+		c.Claims, c.Disclosures = claims, disclosures
+		return w.bound(claims)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, c.Format)
+	}
+}
+
+// bound compares the holder and the cnf key of the credential, where present, with the holder key.
+func (w *Wallet) bound(claims map[string]any) error {
+	if holder, ok := claims["holder"].(string); ok && holder != w.holder.Did {
+		return fmt.Errorf("%w: holder is %s", ErrHolderMismatch, holder)
+	}
+
+	cnf, _ := claims["cnf"].(map[string]any)
+	if cnf == nil || cnf["jwk"] == nil {
+		return nil
+	}
+	b, err := json.Marshal(cnf["jwk"])
+	if err != nil {
+		return err
+	}
+	key, err := jwk.ParseKey(b)
+	if err != nil {
+		return fmt.Errorf("%w: cnf.jwk: %w", ErrHolderMismatch, err)
+	}
+	pub, err := w.holder.PublicKey()
+	if err != nil {
+		return err
+	}
+	if !jwk.Equal(key, pub) {
+		return fmt.Errorf("%w: cnf.jwk is another key", ErrHolderMismatch)
+	}
+	return nil
+}
+
+// encryptionParameters returns a new decryption key and the parameters naming its public key.
+func encryptionParameters() (jwk.Key, *responseEncryption, error) {
+	key, err := newKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := key.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &responseEncryption{Jwk: pub, Alg: jwa.ECDH_ES.String(), Enc: jwa.A256GCM.String()}, nil
+}
+
+// decrypt replaces the compact JWE in the credential by the credential of the decrypted response.
+func decrypt(c *Credential, key jwk.Key) error {
+	compact, ok := c.Credential.(string)
+	if !ok || key == nil {
+		return ErrDecryption
+	}
+	b, err := jwe.Decrypt([]byte(compact), jwe.WithKey(jwa.ECDH_ES, key))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+
+	var response struct {
+		Credential     any    `json:"credential"`
+		NotificationId string `json:"notification_id"`
+	}
+	if err := json.Unmarshal(b, &response); err != nil {
+		return fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+	c.Credential, c.NotificationId = response.Credential, response.NotificationId
+	return nil
+}